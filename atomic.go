@@ -0,0 +1,118 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// Atomic config file writing with backups.
+
+package config
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+)
+
+// BackupSuffix is appended to the name of a config file to name its backup
+// written by WriteConfigFileBackup.
+const BackupSuffix = ".bak"
+
+// ErrBackupRead is returned by ReadConfigFile and functions that use it when
+// a config file fails to decode and its backup is read instead. It is an
+// ErrWarning that wraps the ErrDecode of the file; config holds the values
+// read from the backup.
+var ErrBackupRead = ErrWarning.WrapFormat("read backup of '%s'")
+
+// writeFileAtomic writes a file specified by filename atomically using write
+// to write its contents to a temporary file in the same directory that is
+// synced and renamed to filename. The file is given permissions and, where
+// supported, ownership of like if not nil and 0644 permissions otherwise.
+func writeFileAtomic(filename string, like os.FileInfo, write func(w io.Writer) error) (err error) {
+	file, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			file.Close()
+			os.Remove(file.Name())
+		}
+	}()
+	if err = write(file); err != nil {
+		return err
+	}
+	if err = file.Sync(); err != nil {
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	mode := os.FileMode(0644)
+	if like != nil {
+		mode = like.Mode().Perm()
+		if err = chownLike(file.Name(), like); err != nil {
+			return err
+		}
+	}
+	if err = os.Chmod(file.Name(), mode); err != nil {
+		return err
+	}
+	if err = os.Rename(file.Name(), filename); err != nil {
+		return err
+	}
+	syncDir(filepath.Dir(filename))
+	return nil
+}
+
+// linkTarget returns the path of the file a symbolic link specified by
+// filename points to so that writes replace the file and not the link. If
+// filename is not an existing symbolic link it is returned as is.
+func linkTarget(filename string) string {
+	if fi, err := os.Lstat(filename); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		return filename
+	}
+	if target, err := filepath.EvalSymlinks(filename); err == nil {
+		return target
+	}
+	return filename
+}
+
+// errNoBackup is returned by a read function of readConfigFile to fail
+// reading the backup without reading it.
+var errNoBackup = errors.New("backup not read")
+
+// readConfigFile opens a file specified by filename and reads it into config
+// using read. If read fails with an ErrDecode and a backup of the file exists
+// config is restored to its state prior to the call and the backup is read
+// instead and an ErrBackupRead is returned. If reading the backup fails as
+// well the error of reading the file is returned. If filename is a symbolic
+// link the backup of its target is read.
+func readConfigFile(filename string, config interface{}, read func(r io.Reader) error) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	bakname := linkTarget(filename) + BackupSuffix
+	v := reflect.ValueOf(config)
+	if _, err := os.Stat(bakname); err != nil || v.Kind() != reflect.Ptr || v.IsNil() {
+		return read(file)
+	}
+	old := copyValue(v.Elem())
+	if err = read(file); err == nil || !errors.Is(err, ErrDecode) {
+		return err
+	}
+	v.Elem().Set(old)
+	backup, berr := os.Open(bakname)
+	if berr != nil {
+		return err
+	}
+	defer backup.Close()
+	if berr = read(backup); berr != nil {
+		v.Elem().Set(old)
+		return err
+	}
+	return ErrBackupRead.WrapCauseArgs(err, filename)
+}
@@ -0,0 +1,16 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package config
+
+import "os"
+
+// chownLike is a no-op on platforms without unix file ownership.
+func chownLike(filename string, like os.FileInfo) error { return nil }
+
+// syncDir is a no-op on platforms that do not support syncing directories.
+func syncDir(dir string) {}
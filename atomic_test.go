@@ -0,0 +1,171 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestWriteConfigFileBackup(t *testing.T) {
+	type Config struct {
+		Name string
+		Age  int
+	}
+	dir, err := ioutil.TempDir("", "configtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "config.json")
+	if err := ioutil.WriteFile(filename, []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteConfigFileBackup(filename, &Config{"Foo", 42}); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteConfigFileBackup(filename, &Config{"Bar", 69}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{filename, filename + BackupSuffix} {
+		fi, err := os.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if runtime.GOOS != "windows" && fi.Mode().Perm() != 0600 {
+			t.Fatalf("WriteConfigFileBackup failed: %s mode %v", name, fi.Mode())
+		}
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("WriteConfigFileBackup failed: %d files in dir", len(files))
+	}
+	in := &Config{}
+	if err := ReadConfigFile(filename, in); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, &Config{"Bar", 69}) {
+		t.Fatalf("ReadConfigFile failed: %#v", in)
+	}
+	if err := ioutil.WriteFile(filename, []byte(`{"Name": "Baz", "Age": `), 0600); err != nil {
+		t.Fatal(err)
+	}
+	in = &Config{}
+	if err := ReadConfigFile(filename, in); !errors.Is(err, ErrBackupRead) || !errors.Is(err, ErrWarning) {
+		t.Fatalf("ReadConfigFile failed: expected ErrBackupRead, got %v", err)
+	}
+	if !reflect.DeepEqual(in, &Config{"Foo", 42}) {
+		t.Fatalf("ReadConfigFile failed: backup not loaded: %#v", in)
+	}
+	if err := ioutil.WriteFile(filename, []byte(`{"$include": "missing.json", "Name": "Baz"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	in = &Config{}
+	if err := ReadConfigFile(filename, in); !errors.Is(err, ErrIncludeNotFound) {
+		t.Fatalf("ReadConfigFile failed: expected ErrIncludeNotFound, got %v", err)
+	}
+	if !reflect.DeepEqual(in, &Config{}) {
+		t.Fatalf("ReadConfigFile failed: backup loaded: %#v", in)
+	}
+	if err := os.Remove(filename + BackupSuffix); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReadConfigFile(filename, in); err == nil {
+		t.Fatal("ReadConfigFile failed: expected decode error")
+	}
+}
+
+func TestWriteConfigFileSymlink(t *testing.T) {
+	type Config struct {
+		Name string
+		Age  int
+	}
+	dir, err := ioutil.TempDir("", "configtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	target := filepath.Join(dir, "target.json")
+	if err := ioutil.WriteFile(target, []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "config.json")
+	if err := os.Symlink(target, link); err != nil {
+		t.Skip(err)
+	}
+	if err := WriteConfigFileBackup(link, &Config{"Foo", 42}); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Lstat(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode()&os.ModeSymlink == 0 {
+		t.Fatal("WriteConfigFileBackup failed: link replaced")
+	}
+	if _, err := os.Stat(target + BackupSuffix); err != nil {
+		t.Fatalf("WriteConfigFileBackup failed: %v", err)
+	}
+	if _, err := os.Lstat(link + BackupSuffix); !os.IsNotExist(err) {
+		t.Fatal("WriteConfigFileBackup failed: backup written beside link")
+	}
+	in := &Config{}
+	if err := ReadConfigFile(target, in); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, &Config{"Foo", 42}) {
+		t.Fatalf("WriteConfigFileBackup failed: target not written: %#v", in)
+	}
+	if err := ioutil.WriteFile(target, []byte(`{"Name": `), 0600); err != nil {
+		t.Fatal(err)
+	}
+	in = &Config{}
+	if err := ReadConfigFile(link, in); !errors.Is(err, ErrBackupRead) {
+		t.Fatalf("ReadConfigFile failed: expected ErrBackupRead, got %v", err)
+	}
+	if !reflect.DeepEqual(in, &Config{}) {
+		t.Fatalf("ReadConfigFile failed: target backup not loaded: %#v", in)
+	}
+}
+
+func TestLoadConfigBackup(t *testing.T) {
+	type Config struct {
+		Name string
+		Age  int
+	}
+	dir, err := NewDir("configtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dir.RemoveUser()
+	if err := dir.SaveUserConfig("backup.json", &Config{"Foo", 42}); err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(dir.User(), "backup.json")
+	if err := WriteConfigFileBackup(filename, &Config{"Bar", 69}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filename, []byte(`{"Name": `), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, override := range []bool{false, true} {
+		in := &Config{}
+		prov, err := dir.LoadConfigProvenance("backup.json", override, false, in)
+		if !errors.Is(err, ErrBackupRead) {
+			t.Fatalf("LoadConfigProvenance failed: expected ErrBackupRead, got %v", err)
+		}
+		if !reflect.DeepEqual(in, &Config{"Foo", 42}) || prov["Name"] != filename {
+			t.Fatalf("LoadConfigProvenance failed: backup not loaded: %#v, %v", in, prov)
+		}
+	}
+}
@@ -6,6 +6,8 @@
 package codec
 
 import (
+	"io"
+	"sort"
 	"sync"
 
 	"github.com/vedranvuk/errorex"
@@ -27,6 +29,27 @@ type Codec interface {
 	Decode([]byte, interface{}) error
 }
 
+// StreamCodec defines an optional Codec interface for codecs that can encode
+// to and decode from streams directly without buffering the whole config.
+type StreamCodec interface {
+	Codec
+	// EncodeTo must encode interface to a writer or return an error.
+	EncodeTo(io.Writer, interface{}) error
+	// DecodeFrom must decode from reader to the interface or return an error.
+	DecodeFrom(io.Reader, interface{}) error
+}
+
+// RawCodec defines an optional Codec interface for codecs that can decode a
+// config document into a raw document without losing precision of numbers,
+// which decoding into a map[string]interface{} using Decode may not retain.
+type RawCodec interface {
+	Codec
+	// DecodeRaw must decode the byte slice to a raw document of nested
+	// map[string]interface{} and []interface{} values with numbers decoded
+	// to values that represent them exactly or return an error.
+	DecodeRaw([]byte) (map[string]interface{}, error)
+}
+
 // Register registers a Config codec under the specified name.
 // It panics if the name is already registered.
 func Register(name string, codec Codec) {
@@ -52,6 +75,18 @@ func Get(name string) (Codec, error) {
 	return filter, nil
 }
 
+// Names returns names of all registered codecs sorted alphabetically.
+func Names() []string {
+	regmu.Lock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	regmu.Unlock()
+	sort.Strings(names)
+	return names
+}
+
 var (
 	// regmu is the codec registry mutex.
 	regmu = sync.Mutex{}
@@ -0,0 +1,199 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// Package env implements key/value Config Codecs for dotenv and Java
+// properties files.
+//
+// Both codecs flatten nested struct fields into keys that are paths to fields
+// on Encode and reverse that on Decode. ENV codec joins uppercased field names
+// with an underscore, e.g. "DATABASE_USER", and Properties codec joins field
+// names with a dot, e.g. "Database.User". Keys are matched to fields case
+// insensitively when decoding and keys that do not address a field are
+// ignored.
+//
+// A field name can be overridden using an "env" tag for ENV codec and a
+// "properties" tag for Properties codec and a field can be skipped by
+// specifying "-" as its name.
+//
+// Scalar values are converted using TextUnmarshaler if the field implements
+// it and strconvex otherwise. Slices of scalars are written as comma
+// separated values whose elements are quoted if they are empty, have leading
+// or trailing whitespace or contain commas or quotes. Maps are supported if
+// their values are scalars and their keys are appended to the path as is.
+package env
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/vedranvuk/config/codec"
+)
+
+// ENV is the dotenv Config Codec.
+//
+// Files it writes can be sourced by a POSIX shell; values that contain
+// characters other than letters, digits and "_-./:,@%+=" are single quoted.
+type ENV struct{}
+
+// envFormat is the key format of ENV codec.
+var envFormat = keyFormat{tag: "env", sep: "_", upper: true}
+
+// Encode implements Codec.Encode.
+func (e *ENV) Encode(config interface{}) ([]byte, error) {
+	kvs, err := encode(config, envFormat)
+	if err != nil {
+		return nil, err
+	}
+	buf := bytes.NewBuffer(nil)
+	for _, kv := range kvs {
+		fmt.Fprintf(buf, "%s=%s\n", kv.key, shellQuote(kv.value))
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode implements Codec.Decode.
+func (e *ENV) Decode(data []byte, config interface{}) error {
+	kvs, err := parseEnv(string(data))
+	if err != nil {
+		return err
+	}
+	return decode(kvs, config, envFormat)
+}
+
+// shellQuote single quotes s if it contains characters that are not safe to
+// be used unquoted in a shell.
+func shellQuote(s string) string {
+	safe := true
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+			strings.ContainsRune("_-./:,@%+=", c)) {
+			safe = false
+			break
+		}
+	}
+	if safe {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// parseEnv parses dotenv formatted data into key/value pairs.
+//
+// Lines are formatted as "KEY=value" with an optional "export " prefix.
+// Values can be unquoted, single quoted or double quoted and quoted values
+// may span multiple lines. Single quoted values are read literally, double
+// quoted values and unquoted values support backslash escapes. Lines and
+// unquoted values are commented out with "#".
+func parseEnv(s string) (result []keyval, err error) {
+	var line, pos = 1, 0
+	skipLine := func() {
+		for pos < len(s) && s[pos] != '\n' {
+			pos++
+		}
+	}
+	for pos < len(s) {
+		switch s[pos] {
+		case '\n':
+			line++
+			fallthrough
+		case ' ', '\t', '\r':
+			pos++
+			continue
+		case '#':
+			skipLine()
+			continue
+		}
+		if strings.HasPrefix(s[pos:], "export ") {
+			pos += len("export ")
+		}
+		eq := strings.IndexAny(s[pos:], "=\n")
+		if eq < 0 || s[pos+eq] != '=' {
+			return nil, ErrSyntax.WrapArgs(line)
+		}
+		key := strings.TrimSpace(s[pos : pos+eq])
+		if key == "" {
+			return nil, ErrSyntax.WrapArgs(line)
+		}
+		pos += eq + 1
+		val := strings.Builder{}
+	Value:
+		for pos < len(s) {
+			c := s[pos]
+			switch c {
+			case '\n', '\r':
+				break Value
+			case '\'':
+				end := strings.IndexByte(s[pos+1:], '\'')
+				if end < 0 {
+					return nil, ErrSyntax.WrapArgs(line)
+				}
+				val.WriteString(s[pos+1 : pos+1+end])
+				line += strings.Count(s[pos+1:pos+1+end], "\n")
+				pos += end + 2
+			case '"':
+				for pos++; ; pos++ {
+					if pos >= len(s) {
+						return nil, ErrSyntax.WrapArgs(line)
+					}
+					if s[pos] == '"' {
+						pos++
+						break
+					}
+					if s[pos] == '\n' {
+						line++
+					}
+					if s[pos] == '\\' && pos+1 < len(s) {
+						pos++
+						switch s[pos] {
+						case '"', '\\', '$', '`':
+							val.WriteByte(s[pos])
+						case 'n':
+							val.WriteByte('\n')
+						case '\n':
+							line++
+						default:
+							val.WriteByte('\\')
+							val.WriteByte(s[pos])
+						}
+						continue
+					}
+					val.WriteByte(s[pos])
+				}
+			case '\\':
+				if pos+1 < len(s) {
+					if s[pos+1] == '\n' {
+						line++
+					} else {
+						val.WriteByte(s[pos+1])
+					}
+				}
+				pos += 2
+			case ' ', '\t':
+				end := pos
+				for end < len(s) && (s[end] == ' ' || s[end] == '\t') {
+					end++
+				}
+				if end >= len(s) || s[end] == '\n' || s[end] == '\r' || s[end] == '#' {
+					pos = end
+					skipLine()
+					break Value
+				}
+				val.WriteString(s[pos:end])
+				pos = end
+			default:
+				val.WriteByte(c)
+				pos++
+			}
+		}
+		result = append(result, keyval{key, val.String()})
+	}
+	return result, nil
+}
+
+// init registers the Filter on package initialization in the filter registry.
+func init() {
+	codec.Register("env", &ENV{})
+	codec.Register("properties", &Properties{})
+}
@@ -0,0 +1,187 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package env
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// Properties is the Java properties Config Codec.
+//
+// Files it writes are escaped as specified for Java properties files with
+// characters outside of printable ASCII written as unicode escapes.
+type Properties struct{}
+
+// propertiesFormat is the key format of Properties codec.
+var propertiesFormat = keyFormat{tag: "properties", sep: ".", upper: false}
+
+// Encode implements Codec.Encode.
+func (p *Properties) Encode(config interface{}) ([]byte, error) {
+	kvs, err := encode(config, propertiesFormat)
+	if err != nil {
+		return nil, err
+	}
+	buf := bytes.NewBuffer(nil)
+	for _, kv := range kvs {
+		fmt.Fprintf(buf, "%s = %s\n", escapeProperty(kv.key, true), escapeProperty(kv.value, false))
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode implements Codec.Decode.
+func (p *Properties) Decode(data []byte, config interface{}) error {
+	kvs, err := parseProperties(string(data))
+	if err != nil {
+		return err
+	}
+	return decode(kvs, config, propertiesFormat)
+}
+
+// escapeProperty escapes s for writing to a properties file. If key is true
+// all spaces are escaped, otherwise only the leading space.
+func escapeProperty(s string, key bool) string {
+	b := strings.Builder{}
+	for i, c := range s {
+		switch c {
+		case ' ':
+			if key || i == 0 {
+				b.WriteByte('\\')
+			}
+			b.WriteByte(' ')
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\\', '=', ':', '#', '!':
+			b.WriteByte('\\')
+			b.WriteRune(c)
+		default:
+			if c >= 0x20 && c <= 0x7e {
+				b.WriteRune(c)
+				continue
+			}
+			for _, r := range utf16.Encode([]rune{c}) {
+				fmt.Fprintf(&b, `\u%04x`, r)
+			}
+		}
+	}
+	return b.String()
+}
+
+// parseProperties parses Java properties formatted data into key/value pairs.
+func parseProperties(s string) (result []keyval, err error) {
+	lines := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimLeft(lines[i], " \t\f")
+		if line == "" || line[0] == '#' || line[0] == '!' {
+			continue
+		}
+		num := i + 1
+		// Join continuation lines ending with an odd number of backslashes.
+		for continues(line) && i+1 < len(lines) {
+			i++
+			line = line[:len(line)-1] + strings.TrimLeft(lines[i], " \t\f")
+		}
+		if continues(line) {
+			line = line[:len(line)-1]
+		}
+		// Key ends at first unescaped separator or whitespace.
+		end := 0
+		for end < len(line) {
+			if line[end] == '\\' {
+				end += 2
+				continue
+			}
+			if strings.IndexByte("=: \t\f", line[end]) >= 0 {
+				break
+			}
+			end++
+		}
+		if end > len(line) {
+			end = len(line)
+		}
+		key, err := unescapeProperty(line[:end])
+		if err != nil {
+			return nil, ErrSyntax.WrapArgs(num)
+		}
+		rest := strings.TrimLeft(line[end:], " \t\f")
+		if rest != "" && (rest[0] == '=' || rest[0] == ':') {
+			rest = strings.TrimLeft(rest[1:], " \t\f")
+		}
+		val, err := unescapeProperty(rest)
+		if err != nil {
+			return nil, ErrSyntax.WrapArgs(num)
+		}
+		result = append(result, keyval{key, val})
+	}
+	return result, nil
+}
+
+// continues returns true if line ends with an odd number of backslashes.
+func continues(line string) bool {
+	n := 0
+	for i := len(line) - 1; i >= 0 && line[i] == '\\'; i-- {
+		n++
+	}
+	return n%2 == 1
+}
+
+// unescapeProperty unescapes an escaped properties key or value s.
+func unescapeProperty(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+	var units []uint16
+	b := strings.Builder{}
+	flush := func() {
+		if len(units) > 0 {
+			b.WriteString(string(utf16.Decode(units)))
+			units = units[:0]
+		}
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 >= len(s) {
+			flush()
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		if s[i] == 'u' {
+			if i+5 > len(s) {
+				return "", ErrSyntax
+			}
+			u, err := strconv.ParseUint(s[i+1:i+5], 16, 16)
+			if err != nil {
+				return "", err
+			}
+			units = append(units, uint16(u))
+			i += 4
+			continue
+		}
+		flush()
+		switch s[i] {
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 'f':
+			b.WriteByte('\f')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	flush()
+	return b.String(), nil
+}
@@ -0,0 +1,236 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package env
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/vedranvuk/config/codec"
+	"github.com/vedranvuk/config/codec/internal/mapper"
+)
+
+var (
+	// ErrEnv is the base error of env package.
+	ErrEnv = codec.ErrCodec.Wrap("env")
+	// ErrInvalidConfig is returned when config is not a pointer to a struct.
+	ErrInvalidConfig = ErrEnv.Wrap("config must be a pointer to a struct")
+	// ErrSyntax is returned when a syntax error is encountered in data.
+	ErrSyntax = ErrEnv.WrapFormat("syntax error on line %d")
+	// ErrUnsupportedField is returned when a field of a type that cannot be
+	// represented as a key/value pair is encountered.
+	ErrUnsupportedField = ErrEnv.WrapFormat("unsupported field '%s'")
+	// ErrInvalidValue is returned when a value cannot be converted to the
+	// type of field it maps to.
+	ErrInvalidValue = ErrEnv.WrapFormat("invalid value for '%s'")
+)
+
+// keyFormat defines how field paths are converted to keys.
+type keyFormat struct {
+	// tag is the name of the struct tag that overrides field names.
+	tag string
+	// sep is the separator between path elements.
+	sep string
+	// upper specifies if field names are uppercased.
+	upper bool
+}
+
+// name returns the name of a struct field as defined by a tag or the name of
+// the field converted per format if not tagged and a bool specifying if it
+// was tagged.
+func (kf keyFormat) name(sf reflect.StructField) (string, bool) {
+	if tag, ok := sf.Tag.Lookup(kf.tag); ok && tag != "" {
+		return tag, true
+	}
+	if kf.upper {
+		return strings.ToUpper(sf.Name), false
+	}
+	return sf.Name, false
+}
+
+// values is the Mapper of env codecs.
+var values = &mapper.Mapper{
+	Lists: true,
+	Unsupported: func(name string) error {
+		return ErrUnsupportedField.WrapArgs(name)
+	},
+	Invalid: func(err error, name string) error {
+		return ErrInvalidValue.WrapCauseArgs(err, name)
+	},
+}
+
+// keyval is a key/value pair.
+type keyval struct {
+	key   string
+	value string
+}
+
+// flatten flattens v which must be a struct into a slice of key/value pairs
+// with keys being paths to values joined using key format.
+func flatten(v reflect.Value, prefix string, kf keyFormat) (result []keyval, err error) {
+	join := func(name string) string {
+		if prefix == "" {
+			return name
+		}
+		return prefix + kf.sep + name
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			sf := v.Type().Field(i)
+			if sf.PkgPath != "" {
+				continue
+			}
+			name, tagged := kf.name(sf)
+			if name == "-" {
+				continue
+			}
+			key := join(name)
+			if sf.Anonymous && !tagged {
+				key = prefix
+			}
+			kvs, err := flattenValue(v.Field(i), key, kf)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, kvs...)
+		}
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		for _, k := range keys {
+			key := join(fmt.Sprint(k.Interface()))
+			if isCompound(v.MapIndex(k)) {
+				return nil, ErrUnsupportedField.WrapArgs(key)
+			}
+			kvs, err := flattenValue(v.MapIndex(k), key, kf)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, kvs...)
+		}
+	}
+	return
+}
+
+// flattenValue flattens v into key/value pairs with key as the path to v.
+func flattenValue(v reflect.Value, key string, kf keyFormat) ([]keyval, error) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+	if isCompound(v) {
+		return flatten(v, key, kf)
+	}
+	s, err := values.Format(v, key)
+	if err != nil {
+		return nil, err
+	}
+	return []keyval{{key, s}}, nil
+}
+
+// isCompound returns true if v is a struct or a map that does not implement
+// TextMarshaler.
+func isCompound(v reflect.Value) bool {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return false
+		}
+		v = v.Elem()
+	}
+	if _, ok := mapper.TextMarshaler(v); ok {
+		return false
+	}
+	return v.Kind() == reflect.Struct || v.Kind() == reflect.Map
+}
+
+// assign sets the value at path key rooted at v to val. Keys that do not
+// address a value are ignored.
+func assign(v reflect.Value, key, val string, kf keyFormat) (bool, error) {
+	if v = mapper.Indirect(v); !v.IsValid() {
+		return false, nil
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		// Exact matches take precedence over path prefixes.
+		for i := 0; i < v.NumField(); i++ {
+			sf := v.Type().Field(i)
+			name, tagged := kf.name(sf)
+			if sf.PkgPath != "" || name == "-" || (sf.Anonymous && !tagged) {
+				continue
+			}
+			if strings.EqualFold(key, name) {
+				return true, values.Set(v.Field(i), val, key)
+			}
+		}
+		for i := 0; i < v.NumField(); i++ {
+			sf := v.Type().Field(i)
+			name, tagged := kf.name(sf)
+			if sf.PkgPath != "" || name == "-" {
+				continue
+			}
+			if sf.Anonymous && !tagged {
+				if ok, err := assign(v.Field(i), key, val, kf); ok || err != nil {
+					return ok, err
+				}
+				continue
+			}
+			prefix := name + kf.sep
+			if len(key) <= len(prefix) || !strings.EqualFold(key[:len(prefix)], prefix) {
+				continue
+			}
+			if ok, err := assign(v.Field(i), key[len(prefix):], val, kf); ok || err != nil {
+				return ok, err
+			}
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			if !v.CanSet() {
+				return false, nil
+			}
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		k := reflect.New(v.Type().Key()).Elem()
+		if err := values.Set(k, key, key); err != nil {
+			return false, err
+		}
+		e := reflect.New(v.Type().Elem()).Elem()
+		if err := values.Set(e, val, key); err != nil {
+			return false, err
+		}
+		v.SetMapIndex(k, e)
+		return true, nil
+	}
+	return false, nil
+}
+
+// encode flattens config to key/value pairs using key format.
+func encode(config interface{}, kf keyFormat) ([]keyval, error) {
+	v := reflect.Indirect(reflect.ValueOf(config))
+	if v.Kind() != reflect.Struct {
+		return nil, ErrInvalidConfig
+	}
+	return flatten(v, "", kf)
+}
+
+// decode assigns key/value pairs to config using key format.
+func decode(kvs []keyval, config interface{}, kf keyFormat) error {
+	v := reflect.ValueOf(config)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return ErrInvalidConfig
+	}
+	for _, kv := range kvs {
+		if _, err := assign(v.Elem(), kv.key, kv.value, kf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -9,6 +9,7 @@ import (
 	"bytes"
 	"encoding/gob"
 	"errors"
+	"io"
 
 	"github.com/vedranvuk/config/codec"
 )
@@ -52,6 +53,21 @@ func (g *GOB) Decode(data []byte, config interface{}) error {
 	return nil
 }
 
+// EncodeTo implements StreamCodec.EncodeTo.
+func (g *GOB) EncodeTo(w io.Writer, config interface{}) error {
+
+	if config == nil {
+		return errors.New("cannot encode nil value")
+	}
+
+	return gob.NewEncoder(w).Encode(config)
+}
+
+// DecodeFrom implements StreamCodec.DecodeFrom.
+func (g *GOB) DecodeFrom(r io.Reader, config interface{}) error {
+	return gob.NewDecoder(r).Decode(config)
+}
+
 // init registers the Filter on package initialization in the filter registry.
 func init() {
 	c := &GOB{
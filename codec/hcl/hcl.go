@@ -0,0 +1,482 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// Package hcl implements an HCL Config Codec.
+//
+// HCL codec decodes blocks into nested struct fields and repeated blocks into
+// slices of structs. Block labels are used as map keys when decoding into
+// maps and are stored to a field tagged as `hcl:",key"` when decoding into
+// structs. Attributes are decoded into scalar fields and lists into slices.
+//
+// Block and attribute names are matched to field names case insensitively.
+// A field name can be overridden using an "hcl" tag and a field can be skipped
+// by specifying "-" as its name.
+//
+// Scalar values are converted using TextUnmarshaler if the field implements
+// it and strconvex otherwise, same as the sanitizer does.
+package hcl
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/ast"
+	"github.com/hashicorp/hcl/hcl/token"
+	"github.com/vedranvuk/config/codec"
+	"github.com/vedranvuk/config/codec/internal/mapper"
+)
+
+var (
+	// ErrHCL is the base error of hcl package.
+	ErrHCL = codec.ErrCodec.Wrap("hcl")
+	// ErrInvalidConfig is returned when config is not a pointer to a struct.
+	ErrInvalidConfig = ErrHCL.Wrap("config must be a pointer to a struct")
+	// ErrUnsupportedField is returned when a field of a type that cannot be
+	// represented in HCL format is encountered.
+	ErrUnsupportedField = ErrHCL.WrapFormat("unsupported field '%s'")
+	// ErrInvalidValue is returned when a value cannot be converted to the
+	// type of field it maps to.
+	ErrInvalidValue = ErrHCL.WrapFormat("invalid value for '%s'")
+)
+
+// HCL is the HCL Config Codec.
+type HCL struct{}
+
+// Encode implements Codec.Encode.
+func (h *HCL) Encode(config interface{}) ([]byte, error) {
+	v := reflect.Indirect(reflect.ValueOf(config))
+	if v.Kind() != reflect.Struct {
+		return nil, ErrInvalidConfig
+	}
+	buf := bytes.NewBuffer(nil)
+	if err := encodeBody(buf, v, 0); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode implements Codec.Decode.
+func (h *HCL) Decode(data []byte, config interface{}) error {
+	v := reflect.ValueOf(config)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return ErrInvalidConfig
+	}
+	file, err := hcl.ParseBytes(data)
+	if err != nil {
+		return err
+	}
+	list, ok := file.Node.(*ast.ObjectList)
+	if !ok {
+		return nil
+	}
+	return decodeBody(v.Elem(), list.Items)
+}
+
+// fieldInfo returns the name of a struct field as defined by an hcl tag or
+// the name of the field if not tagged, a bool specifying if it was tagged and
+// a bool specifying if the field is a block label key field.
+func fieldInfo(sf reflect.StructField) (name string, tagged, key bool) {
+	tag, ok := sf.Tag.Lookup("hcl")
+	if !ok {
+		return sf.Name, false, false
+	}
+	parts := strings.Split(tag, ",")
+	for _, opt := range parts[1:] {
+		if opt == "key" {
+			key = true
+		}
+	}
+	if parts[0] == "" {
+		return sf.Name, false, key
+	}
+	return parts[0], true, key
+}
+
+// fieldName returns the name of a non-key struct field as defined by an hcl
+// tag or the name of the field if not tagged and a bool specifying if it was
+// tagged. Key fields are named "-".
+func fieldName(sf reflect.StructField) (string, bool) {
+	name, tagged, key := fieldInfo(sf)
+	if key {
+		return "-", false
+	}
+	return name, tagged
+}
+
+// values is the Mapper of HCL codec.
+var values = &mapper.Mapper{
+	Name: fieldName,
+	Unsupported: func(name string) error {
+		return ErrUnsupportedField.WrapArgs(name)
+	},
+	Invalid: func(err error, name string) error {
+		return ErrInvalidValue.WrapCauseArgs(err, name)
+	},
+}
+
+// keyField returns the field of struct v tagged as block label key or an
+// invalid value if not found.
+func keyField(v reflect.Value) reflect.Value {
+	for i := 0; i < v.NumField(); i++ {
+		sf := v.Type().Field(i)
+		if _, _, key := fieldInfo(sf); key && sf.PkgPath == "" {
+			return v.Field(i)
+		}
+	}
+	return reflect.Value{}
+}
+
+// keyName returns the name of an object key.
+func keyName(key *ast.ObjectKey) string {
+	if key.Token.Type == token.STRING {
+		return fmt.Sprint(key.Token.Value())
+	}
+	return key.Token.Text
+}
+
+// decodeBody decodes items into v which must be a struct or a map.
+// Slices are replaced by the first block or attribute addressing them in
+// items and appended to by any following blocks.
+func decodeBody(v reflect.Value, items []*ast.ObjectItem) error {
+	seen := make(map[string]bool)
+	for _, item := range items {
+		if len(item.Keys) == 0 {
+			continue
+		}
+		name := keyName(item.Keys[0])
+		switch v.Kind() {
+		case reflect.Struct:
+			fld := values.Field(v, name)
+			if !fld.IsValid() {
+				continue
+			}
+			if target := mapper.Indirect(fld); target.Kind() == reflect.Slice && !seen[strings.ToLower(name)] {
+				target.Set(reflect.MakeSlice(target.Type(), 0, 0))
+			}
+			seen[strings.ToLower(name)] = true
+			if err := decodeItem(fld, item.Keys[1:], item.Val, name); err != nil {
+				return err
+			}
+		case reflect.Map:
+			if err := decodeItem(v, item.Keys, item.Val, name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// decodeItem decodes node into v using labels as map keys or key field
+// values. name is the name of the item and is used for error reporting.
+func decodeItem(v reflect.Value, labels []*ast.ObjectKey, node ast.Node, name string) error {
+	if v = mapper.Indirect(v); !v.IsValid() {
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Map:
+		if len(labels) == 0 {
+			break
+		}
+		if v.IsNil() {
+			if !v.CanSet() {
+				return nil
+			}
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		k := reflect.New(v.Type().Key()).Elem()
+		if err := values.Set(k, keyName(labels[0]), name); err != nil {
+			return err
+		}
+		e := reflect.New(v.Type().Elem()).Elem()
+		if existing := v.MapIndex(k); existing.IsValid() {
+			e.Set(existing)
+		}
+		if err := decodeItem(e, labels[1:], node, name); err != nil {
+			return err
+		}
+		v.SetMapIndex(k, e)
+		return nil
+	case reflect.Slice:
+		if _, ok := node.(*ast.ListType); ok && len(labels) == 0 {
+			break
+		}
+		if !v.CanSet() {
+			return nil
+		}
+		e := reflect.New(v.Type().Elem()).Elem()
+		if err := decodeItem(e, labels, node, name); err != nil {
+			return err
+		}
+		v.Set(reflect.Append(v, e))
+		return nil
+	case reflect.Struct:
+		if len(labels) == 0 {
+			break
+		}
+		if key := keyField(v); key.IsValid() {
+			if err := values.Set(key, keyName(labels[0]), name); err != nil {
+				return err
+			}
+		}
+		return decodeItem(v, labels[1:], node, name)
+	}
+	return decodeNode(v, node, name)
+}
+
+// decodeNode decodes node into v. name is the name of the item being decoded
+// and is used for error reporting.
+func decodeNode(v reflect.Value, node ast.Node, name string) error {
+	if v = mapper.Indirect(v); !v.IsValid() {
+		return nil
+	}
+	switch n := node.(type) {
+	case *ast.ObjectType:
+		switch v.Kind() {
+		case reflect.Struct:
+			return decodeBody(v, n.List.Items)
+		case reflect.Map:
+			if v.IsNil() {
+				if !v.CanSet() {
+					return nil
+				}
+				v.Set(reflect.MakeMap(v.Type()))
+			}
+			return decodeBody(v, n.List.Items)
+		case reflect.Slice:
+			return decodeItem(v, nil, n, name)
+		case reflect.Interface:
+			// Uninitialized Interface values are skipped.
+			return nil
+		}
+		return ErrUnsupportedField.WrapArgs(name)
+	case *ast.ListType:
+		if v.Kind() != reflect.Slice {
+			return ErrUnsupportedField.WrapArgs(name)
+		}
+		if !v.CanSet() {
+			return nil
+		}
+		slice := reflect.MakeSlice(v.Type(), len(n.List), len(n.List))
+		for i := 0; i < len(n.List); i++ {
+			if err := decodeNode(slice.Index(i), n.List[i], name); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		return nil
+	case *ast.LiteralType:
+		switch n.Token.Type {
+		case token.STRING, token.HEREDOC:
+			return values.Set(v, fmt.Sprint(n.Token.Value()), name)
+		default:
+			return values.Set(v, n.Token.Text, name)
+		}
+	}
+	return ErrUnsupportedField.WrapArgs(name)
+}
+
+// elem dereferences pointers and interfaces in v and returns the resulting
+// value and true or an invalid value and false if a nil is encountered.
+func elem(v reflect.Value) (reflect.Value, bool) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}, false
+		}
+		v = v.Elem()
+	}
+	return v, true
+}
+
+// isBlock returns true if v is a struct or a map that does not implement
+// TextMarshaler.
+func isBlock(v reflect.Value) bool {
+	if _, ok := mapper.TextMarshaler(v); ok {
+		return false
+	}
+	return v.Kind() == reflect.Struct || v.Kind() == reflect.Map
+}
+
+// isBlockSlice returns true if v is a slice or array whose elements are
+// blocks.
+func isBlockSlice(v reflect.Value) bool {
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return false
+	}
+	if _, ok := mapper.TextMarshaler(v); ok {
+		return false
+	}
+	t := v.Type().Elem()
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if mapper.IsTextMarshaler(t) {
+		return false
+	}
+	return t.Kind() == reflect.Struct || t.Kind() == reflect.Map
+}
+
+// encodeBody writes entries of v which must be a struct or a map to buf as
+// attributes followed by blocks indented by depth levels.
+func encodeBody(buf *bytes.Buffer, v reflect.Value, depth int) error {
+	indent := strings.Repeat("  ", depth)
+	var blocks []mapper.Entry
+	for _, e := range values.Entries(v) {
+		val, ok := elem(e.Value)
+		if !ok {
+			continue
+		}
+		if isBlock(val) || isBlockSlice(val) {
+			blocks = append(blocks, mapper.Entry{Name: e.Name, Value: val})
+			continue
+		}
+		s, err := formatValue(val, e.Name)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(buf, "%s%s = %s\n", indent, formatKey(e.Name), s)
+	}
+	for _, e := range blocks {
+		if !isBlockSlice(e.Value) {
+			if err := encodeBlock(buf, e.Name, e.Value, depth, true); err != nil {
+				return err
+			}
+			continue
+		}
+		for i := 0; i < e.Value.Len(); i++ {
+			val, ok := elem(e.Value.Index(i))
+			if !ok {
+				continue
+			}
+			if err := encodeBlock(buf, e.Name, val, depth, true); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// encodeBlock writes v which must be a struct or a map as a block named name
+// to buf. Maps whose values are blocks are written as blocks labeled with map
+// keys, otherwise if keyed is true and v is a struct with a key field the
+// block is labeled with the key field value.
+func encodeBlock(buf *bytes.Buffer, name string, v reflect.Value, depth int, keyed bool) error {
+	indent := strings.Repeat("  ", depth)
+	if v.Kind() == reflect.Map && isBlockSlice(reflect.MakeSlice(reflect.SliceOf(v.Type().Elem()), 0, 0)) {
+		for _, e := range values.Entries(v) {
+			val, ok := elem(e.Value)
+			if !ok {
+				continue
+			}
+			if err := encodeBlock(buf, name+" "+strconv.Quote(e.Name), val, depth, false); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	label := ""
+	if v.Kind() == reflect.Struct && keyed {
+		if key := keyField(v); key.IsValid() {
+			s, err := formatString(key, name)
+			if err != nil {
+				return err
+			}
+			label = " " + strconv.Quote(s)
+		}
+	}
+	if buf.Len() > 0 {
+		buf.WriteByte('\n')
+	}
+	fmt.Fprintf(buf, "%s%s%s {\n", indent, formatKey(name), label)
+	if err := encodeBody(buf, v, depth+1); err != nil {
+		return err
+	}
+	fmt.Fprintf(buf, "%s}\n", indent)
+	return nil
+}
+
+// formatKey quotes the key part of name if it is not a valid identifier.
+func formatKey(name string) string {
+	key := name
+	rest := ""
+	if i := strings.IndexByte(name, ' '); i >= 0 && strings.HasPrefix(name[i:], ` "`) {
+		key, rest = name[:i], name[i:]
+	}
+	for i, c := range key {
+		if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' ||
+			i > 0 && (c >= '0' && c <= '9' || c == '-' || c == '.')) {
+			return strconv.Quote(key) + rest
+		}
+	}
+	if key == "" {
+		return `""` + rest
+	}
+	return key + rest
+}
+
+// formatString returns a scalar v formatted as a string. name is the name of
+// the item being formatted and is used for error reporting.
+func formatString(v reflect.Value, name string) (string, error) {
+	if tm, ok := mapper.TextMarshaler(v); ok {
+		data, err := tm.MarshalText()
+		if err != nil {
+			return "", ErrInvalidValue.WrapCauseArgs(err, name)
+		}
+		return string(data), nil
+	}
+	return fmt.Sprint(v.Interface()), nil
+}
+
+// formatValue returns a scalar or a slice of scalars v formatted as an HCL
+// value. name is the name of the item being formatted and is used for error
+// reporting.
+func formatValue(v reflect.Value, name string) (string, error) {
+	v, ok := elem(v)
+	if !ok {
+		return `""`, nil
+	}
+	if _, ok := mapper.TextMarshaler(v); ok {
+		s, err := formatString(v, name)
+		if err != nil {
+			return "", err
+		}
+		return strconv.Quote(s), nil
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		s := strconv.FormatFloat(v.Float(), 'g', -1, 64)
+		if !strings.ContainsAny(s, ".eEnN") {
+			s += ".0"
+		}
+		return s, nil
+	case reflect.String:
+		return strconv.Quote(v.String()), nil
+	case reflect.Slice, reflect.Array:
+		vals := make([]string, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			s, err := formatValue(v.Index(i), name)
+			if err != nil {
+				return "", err
+			}
+			vals = append(vals, s)
+		}
+		return "[" + strings.Join(vals, ", ") + "]", nil
+	}
+	return "", ErrUnsupportedField.WrapArgs(name)
+}
+
+// init registers the Filter on package initialization in the filter registry.
+func init() {
+	codec.Register("hcl", &HCL{})
+}
@@ -0,0 +1,256 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// Package ini implements an INI Config Codec.
+//
+// INI codec maps INI sections to nested struct fields and keys to scalar
+// fields of the struct the section maps to. Keys that appear before any
+// section map to fields of the config struct itself. Nested sections are
+// named as a dot separated path to the field, e.g. "[Database.Replica]".
+//
+// Section and key names are matched to field names case insensitively. A
+// field name can be overridden using an "ini" tag and a field can be skipped
+// by specifying "-" as its name.
+//
+// Scalar values are converted using TextUnmarshaler if the field implements
+// it and strconvex otherwise. Slices of scalars are written as comma
+// separated values whose elements are quoted if they are empty, have leading
+// or trailing whitespace or contain commas or quotes. Maps are supported if
+// their values are scalars.
+// Values that have leading or trailing whitespace or contain comment or quote
+// characters are written quoted.
+//
+// Lines starting with ";" or "#" are comments.
+package ini
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/vedranvuk/config/codec"
+	"github.com/vedranvuk/config/codec/internal/mapper"
+)
+
+var (
+	// ErrINI is the base error of ini package.
+	ErrINI = codec.ErrCodec.Wrap("ini")
+	// ErrInvalidConfig is returned when config is not a pointer to a struct.
+	ErrInvalidConfig = ErrINI.Wrap("config must be a pointer to a struct")
+	// ErrSyntax is returned when a syntax error is encountered in INI data.
+	ErrSyntax = ErrINI.WrapFormat("syntax error on line %d")
+	// ErrUnsupportedField is returned when a field of a type that cannot be
+	// represented in INI format is encountered.
+	ErrUnsupportedField = ErrINI.WrapFormat("unsupported field '%s'")
+	// ErrInvalidValue is returned when a value cannot be converted to the
+	// type of field it maps to.
+	ErrInvalidValue = ErrINI.WrapFormat("invalid value for '%s'")
+)
+
+// INI is the INI Config Codec.
+type INI struct{}
+
+// Encode implements Codec.Encode.
+func (i *INI) Encode(config interface{}) ([]byte, error) {
+	v := reflect.Indirect(reflect.ValueOf(config))
+	if v.Kind() != reflect.Struct {
+		return nil, ErrInvalidConfig
+	}
+	buf := bytes.NewBuffer(nil)
+	if err := encodeSection(buf, v, ""); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode implements Codec.Decode.
+func (i *INI) Decode(data []byte, config interface{}) error {
+	v := reflect.ValueOf(config)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return ErrInvalidConfig
+	}
+	v = v.Elem()
+	sect := v
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		s := strings.TrimSpace(scanner.Text())
+		if s == "" || s[0] == ';' || s[0] == '#' {
+			continue
+		}
+		if s[0] == '[' {
+			if s[len(s)-1] != ']' {
+				return ErrSyntax.WrapArgs(line)
+			}
+			sect = section(v, strings.Split(strings.TrimSpace(s[1:len(s)-1]), "."))
+			continue
+		}
+		kv := strings.SplitN(s, "=", 2)
+		if len(kv) != 2 {
+			return ErrSyntax.WrapArgs(line)
+		}
+		val, err := unquote(strings.TrimSpace(kv[1]))
+		if err != nil {
+			return ErrSyntax.WrapArgs(line)
+		}
+		if err := setKey(sect, strings.TrimSpace(kv[0]), val); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// fieldName returns the name of a struct field as defined by an ini tag or
+// the name of the field if not tagged and a bool specifying if it was tagged.
+func fieldName(sf reflect.StructField) (string, bool) {
+	if tag, ok := sf.Tag.Lookup("ini"); ok && tag != "" {
+		return tag, true
+	}
+	return sf.Name, false
+}
+
+// values is the Mapper of INI codec.
+var values = &mapper.Mapper{
+	Name:  fieldName,
+	Lists: true,
+	Unsupported: func(name string) error {
+		return ErrUnsupportedField.WrapArgs(name)
+	},
+	Invalid: func(err error, name string) error {
+		return ErrInvalidValue.WrapCauseArgs(err, name)
+	},
+}
+
+// section returns the struct or map value at path rooted at v or an invalid
+// value if path does not address a struct or map.
+func section(v reflect.Value, path []string) reflect.Value {
+	for _, name := range path {
+		if v = mapper.Indirect(v); v.Kind() != reflect.Struct {
+			return reflect.Value{}
+		}
+		if v = values.Field(v, strings.TrimSpace(name)); !v.IsValid() {
+			return v
+		}
+	}
+	return mapper.Indirect(v)
+}
+
+// setKey sets the value of a field or map element named key in sect to val.
+// Keys in sections that do not map to a struct or map are ignored.
+func setKey(sect reflect.Value, key, val string) error {
+	switch sect.Kind() {
+	case reflect.Struct:
+		if fld := values.Field(sect, key); fld.IsValid() {
+			return values.Set(fld, val, key)
+		}
+	case reflect.Map:
+		if sect.IsNil() {
+			if !sect.CanSet() {
+				return nil
+			}
+			sect.Set(reflect.MakeMap(sect.Type()))
+		}
+		k := reflect.New(sect.Type().Key()).Elem()
+		if err := values.Set(k, key, key); err != nil {
+			return err
+		}
+		e := reflect.New(sect.Type().Elem()).Elem()
+		if err := values.Set(e, val, key); err != nil {
+			return err
+		}
+		sect.SetMapIndex(k, e)
+	}
+	return nil
+}
+
+// encodeSection writes scalar entries of v which must be a struct or map to
+// buf as keys under a section named path followed by its' non-scalar entries
+// as nested sections.
+func encodeSection(buf *bytes.Buffer, v reflect.Value, path string) error {
+	if path != "" {
+		fmt.Fprintf(buf, "[%s]\n", path)
+	}
+	var sections []mapper.Entry
+	for _, e := range values.Entries(v) {
+		val := e.Value
+		for val.Kind() == reflect.Ptr || val.Kind() == reflect.Interface {
+			if val.IsNil() {
+				break
+			}
+			val = val.Elem()
+		}
+		if (val.Kind() == reflect.Ptr || val.Kind() == reflect.Interface) && val.IsNil() {
+			continue
+		}
+		if _, ok := mapper.TextMarshaler(val); !ok && (val.Kind() == reflect.Struct || val.Kind() == reflect.Map) {
+			sections = append(sections, mapper.Entry{Name: e.Name, Value: val})
+			continue
+		}
+		s, err := values.Format(val, e.Name)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(buf, "%s = %s\n", e.Name, quote(s))
+	}
+	for _, e := range sections {
+		if e.Value.Kind() == reflect.Map {
+			if err := checkMap(e.Value, e.Name); err != nil {
+				return err
+			}
+		}
+		if buf.Len() > 0 {
+			buf.WriteByte('\n')
+		}
+		name := e.Name
+		if path != "" {
+			name = path + "." + name
+		}
+		if err := encodeSection(buf, e.Value, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkMap returns ErrUnsupportedField if map v contains non-scalar values.
+func checkMap(v reflect.Value, name string) error {
+	t := v.Type().Elem()
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if mapper.IsTextMarshaler(t) {
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Interface:
+		return ErrUnsupportedField.WrapArgs(name)
+	}
+	return nil
+}
+
+// quote quotes s if it has leading or trailing whitespace or contains
+// characters that would otherwise be misinterpreted when reading it back.
+func quote(s string) string {
+	if s != strings.TrimSpace(s) || strings.ContainsAny(s, ";#\"\n\r") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// unquote unquotes s if it is quoted.
+func unquote(s string) (string, error) {
+	if len(s) > 0 && s[0] == '"' {
+		return strconv.Unquote(s)
+	}
+	return s, nil
+}
+
+// init registers the Filter on package initialization in the filter registry.
+func init() {
+	c := &INI{}
+	codec.Register("ini", c)
+	codec.Register("cfg", c)
+}
@@ -0,0 +1,297 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// Package mapper implements mapping of config struct fields and map elements
+// to and from named text values shared by text based codecs.
+//
+// Scalar values are converted using TextUnmarshaler and TextMarshaler if the
+// value implements them and strconvex and fmt otherwise. Slices of scalars
+// are optionally converted to and from comma separated lists whose elements
+// are quoted if they would not read back as written.
+package mapper
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vedranvuk/strconvex"
+)
+
+// Mapper maps values of a codec.
+type Mapper struct {
+	// Name must return the name of a struct field as defined by a tag or the
+	// name of the field if not tagged and a bool specifying if it was tagged.
+	// Fields named "-" are skipped. Name is required by Entries and Field.
+	Name func(sf reflect.StructField) (name string, tagged bool)
+	// Lists specifies if slices of scalars are converted to and from comma
+	// separated lists. If false slices are unsupported.
+	Lists bool
+	// Unsupported must return the error of the codec for an item named name
+	// of a type that the codec does not support.
+	Unsupported func(name string) error
+	// Invalid must return the error of the codec for a value of an item named
+	// name that failed to convert with err.
+	Invalid func(err error, name string) error
+}
+
+// Entry is a named struct field or map element.
+type Entry struct {
+	// Name is the name of the field or the formatted key of the element.
+	Name string
+	// Value is the field or element value.
+	Value reflect.Value
+}
+
+// Entries returns exported fields of a struct or elements of a map in v as
+// entries. Fields of untagged embedded structs are returned as fields of v.
+// Map elements are sorted by name.
+func (m *Mapper) Entries(v reflect.Value) (result []Entry) {
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			sf := v.Type().Field(i)
+			if sf.PkgPath != "" {
+				continue
+			}
+			name, tagged := m.Name(sf)
+			if name == "-" {
+				continue
+			}
+			if sf.Anonymous && !tagged && reflect.Indirect(v.Field(i)).Kind() == reflect.Struct {
+				result = append(result, m.Entries(reflect.Indirect(v.Field(i)))...)
+				continue
+			}
+			result = append(result, Entry{name, v.Field(i)})
+		}
+	case reflect.Map:
+		for iter := v.MapRange(); iter.Next(); {
+			result = append(result, Entry{fmt.Sprint(iter.Key().Interface()), iter.Value()})
+		}
+		sort.Slice(result, func(i, j int) bool {
+			return result[i].Name < result[j].Name
+		})
+	}
+	return
+}
+
+// Field returns the field of struct v whose name case insensitively matches
+// name or an invalid value if not found. Fields of untagged embedded structs
+// are matched as fields of v.
+func (m *Mapper) Field(v reflect.Value, name string) reflect.Value {
+	for i := 0; i < v.NumField(); i++ {
+		sf := v.Type().Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+		fname, tagged := m.Name(sf)
+		if fname == "-" {
+			continue
+		}
+		if sf.Anonymous && !tagged {
+			if embedded := Indirect(v.Field(i)); embedded.Kind() == reflect.Struct {
+				if fld := m.Field(embedded, name); fld.IsValid() {
+					return fld
+				}
+				continue
+			}
+		}
+		if strings.EqualFold(fname, name) {
+			return v.Field(i)
+		}
+	}
+	return reflect.Value{}
+}
+
+// Set sets v to s converted to type of v. name is the name of the item being
+// set and is used for error reporting.
+func (m *Mapper) Set(v reflect.Value, s, name string) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return m.Set(v.Elem(), s, name)
+	}
+	if v.CanAddr() {
+		if tu, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			if err := tu.UnmarshalText([]byte(s)); err != nil {
+				return m.Invalid(err, name)
+			}
+			return nil
+		}
+	}
+	switch v.Kind() {
+	case reflect.Slice:
+		if !m.Lists {
+			return m.Unsupported(name)
+		}
+		vals, err := splitList(s)
+		if err != nil {
+			return m.Invalid(err, name)
+		}
+		slice := reflect.MakeSlice(v.Type(), len(vals), len(vals))
+		for i := 0; i < len(vals); i++ {
+			if err := m.Set(slice.Index(i), vals[i], name); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		return nil
+	case reflect.Interface:
+		if v.NumMethod() > 0 {
+			return m.Unsupported(name)
+		}
+		v.Set(reflect.ValueOf(s))
+		return nil
+	case reflect.Array, reflect.Chan, reflect.Func, reflect.Map, reflect.Struct,
+		reflect.UnsafePointer:
+		return m.Unsupported(name)
+	}
+	if err := strconvex.StringToValue(s, v); err != nil {
+		return m.Invalid(err, name)
+	}
+	return nil
+}
+
+// Format returns a scalar or, if Lists is true, a slice of scalars v
+// formatted as a string. name is the name of the item being formatted and is
+// used for error reporting.
+func (m *Mapper) Format(v reflect.Value, name string) (string, error) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return "", nil
+		}
+		v = v.Elem()
+	}
+	if tm, ok := TextMarshaler(v); ok {
+		data, err := tm.MarshalText()
+		if err != nil {
+			return "", m.Invalid(err, name)
+		}
+		return string(data), nil
+	}
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		if !m.Lists {
+			return "", m.Unsupported(name)
+		}
+		vals := make([]string, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			s, err := m.Format(v.Index(i), name)
+			if err != nil {
+				return "", err
+			}
+			vals = append(vals, quoteElem(s))
+		}
+		return strings.Join(vals, ","), nil
+	case reflect.Chan, reflect.Func, reflect.Map, reflect.Struct,
+		reflect.UnsafePointer:
+		return "", m.Unsupported(name)
+	}
+	return fmt.Sprint(v.Interface()), nil
+}
+
+// Indirect dereferences v until a non-pointer value is reached, allocating
+// nil pointers if possible. Interfaces that contain a pointer are traversed.
+// It returns an invalid value if a nil pointer cannot be allocated.
+func Indirect(v reflect.Value) reflect.Value {
+	for {
+		switch v.Kind() {
+		case reflect.Ptr:
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		case reflect.Interface:
+			if v.IsNil() || v.Elem().Kind() != reflect.Ptr {
+				return v
+			}
+			v = v.Elem()
+		default:
+			return v
+		}
+	}
+}
+
+// TextMarshaler returns v as a TextMarshaler if it or a pointer to it
+// implements the interface.
+func TextMarshaler(v reflect.Value) (encoding.TextMarshaler, bool) {
+	if !v.IsValid() {
+		return nil, false
+	}
+	if tm, ok := v.Interface().(encoding.TextMarshaler); ok {
+		return tm, true
+	}
+	if v.CanAddr() {
+		if tm, ok := v.Addr().Interface().(encoding.TextMarshaler); ok {
+			return tm, true
+		}
+	}
+	return nil, false
+}
+
+// IsTextMarshaler returns true if t or a pointer to t implements
+// TextMarshaler.
+func IsTextMarshaler(t reflect.Type) bool {
+	return t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType)
+}
+
+// textMarshalerType is the reflect.Type of encoding.TextMarshaler.
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// quoteElem quotes list element s if it is empty, has leading or trailing
+// whitespace or contains a comma or a quote so that it reads back as is.
+func quoteElem(s string) string {
+	if s == "" || s != strings.TrimSpace(s) || strings.ContainsAny(s, `,"`) {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// splitList splits a comma separated list s into elements. Unquoted elements
+// are trimmed of surrounding whitespace and elements starting with a quote
+// are unquoted. An empty s is an empty list.
+func splitList(s string) (result []string, err error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	for {
+		s = strings.TrimLeft(s, " \t")
+		elem := ""
+		if strings.HasPrefix(s, `"`) {
+			end := 1
+			for ; end < len(s) && s[end] != '"'; end++ {
+				if s[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(s) {
+				return nil, strconv.ErrSyntax
+			}
+			if elem, err = strconv.Unquote(s[:end+1]); err != nil {
+				return nil, err
+			}
+			if s = strings.TrimLeft(s[end+1:], " \t"); s != "" && s[0] != ',' {
+				return nil, strconv.ErrSyntax
+			}
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end < 0 {
+				end = len(s)
+			}
+			elem, s = strings.TrimSpace(s[:end]), s[end:]
+		}
+		result = append(result, elem)
+		if s == "" {
+			return result, nil
+		}
+		s = s[1:]
+	}
+}
@@ -6,7 +6,9 @@
 package json
 
 import (
+	"bytes"
 	"encoding/json"
+	"io"
 
 	"github.com/vedranvuk/config/codec"
 )
@@ -24,6 +26,30 @@ func (j *JSON) Decode(data []byte, config interface{}) error {
 	return json.Unmarshal(data, config)
 }
 
+// DecodeRaw implements RawCodec.DecodeRaw. Numbers are decoded as
+// json.Number.
+func (j *JSON) DecodeRaw(data []byte) (map[string]interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc map[string]interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// EncodeTo implements StreamCodec.EncodeTo.
+func (j *JSON) EncodeTo(w io.Writer, config interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(config)
+}
+
+// DecodeFrom implements StreamCodec.DecodeFrom.
+func (j *JSON) DecodeFrom(r io.Reader, config interface{}) error {
+	return json.NewDecoder(r).Decode(config)
+}
+
 // init registers the Filter on package initialization in the filter registry.
 func init() {
 	codec.Register("json", &JSON{})
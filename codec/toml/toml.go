@@ -0,0 +1,101 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// Package toml implements a TOML Config Codec.
+//
+// TOML codec converts configs to and from JSON using the encoding/json
+// package so that field naming, json tags and Interface handling are the same
+// as with the JSON codec. Nested structs are represented as TOML tables and
+// slices of structs as arrays of tables.
+package toml
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/BurntSushi/toml"
+	"github.com/vedranvuk/config/codec"
+)
+
+// TOML is the TOML Config Codec.
+type TOML struct{}
+
+// Encode implements Codec.Encode.
+func (t *TOML) Encode(config interface{}) ([]byte, error) {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc map[string]interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	buf := bytes.NewBuffer(nil)
+	if err := toml.NewEncoder(buf).Encode(tomlify(doc)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode implements Codec.Decode.
+func (t *TOML) Decode(data []byte, config interface{}) error {
+	var doc map[string]interface{}
+	if err := toml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, config)
+}
+
+// DecodeRaw implements RawCodec.DecodeRaw. Numbers are decoded as int64 and
+// float64 by the toml package.
+func (t *TOML) DecodeRaw(data []byte) (map[string]interface{}, error) {
+	var doc map[string]interface{}
+	if err := toml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// tomlify prepares a generic JSON value v for encoding to TOML. It removes
+// null values which TOML cannot represent at any depth in v and converts JSON
+// numbers to integers where possible or floats otherwise.
+func tomlify(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for key, val := range t {
+			if val == nil {
+				delete(t, key)
+				continue
+			}
+			t[key] = tomlify(val)
+		}
+		return t
+	case []interface{}:
+		for i := 0; i < len(t); i++ {
+			t[i] = tomlify(t[i])
+		}
+		return t
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i
+		}
+		if f, err := t.Float64(); err == nil {
+			return f
+		}
+		return t.String()
+	default:
+		return v
+	}
+}
+
+// init registers the Filter on package initialization in the filter registry.
+func init() {
+	codec.Register("toml", &TOML{})
+}
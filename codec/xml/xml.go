@@ -7,6 +7,7 @@ package xml
 
 import (
 	"encoding/xml"
+	"io"
 
 	"github.com/vedranvuk/config/codec"
 )
@@ -24,6 +25,16 @@ func (x *XML) Decode(data []byte, config interface{}) error {
 	return xml.Unmarshal(data, config)
 }
 
+// EncodeTo implements StreamCodec.EncodeTo.
+func (x *XML) EncodeTo(w io.Writer, config interface{}) error {
+	return xml.NewEncoder(w).Encode(config)
+}
+
+// DecodeFrom implements StreamCodec.DecodeFrom.
+func (x *XML) DecodeFrom(r io.Reader, config interface{}) error {
+	return xml.NewDecoder(r).Decode(config)
+}
+
 // init registers the Filter on package initialization in the filter registry.
 func init() {
 	codec.Register("xml", &XML{})
@@ -0,0 +1,92 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// Package yaml implements a YAML Config Codec.
+//
+// YAML codec converts configs to and from JSON using the encoding/json
+// package so that field naming, json tags and Interface handling are the same
+// as with the JSON codec.
+package yaml
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/vedranvuk/config/codec"
+	"gopkg.in/yaml.v2"
+)
+
+// YAML is the YAML Config Codec.
+type YAML struct{}
+
+// Encode implements Codec.Encode.
+func (y *YAML) Encode(config interface{}) ([]byte, error) {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	// JSON is valid YAML; MapSlice retains the order of struct fields.
+	var doc interface{} = &yaml.MapSlice{}
+	if len(data) == 0 || data[0] != '{' {
+		doc = new(interface{})
+	}
+	if err := yaml.Unmarshal(data, doc); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(doc)
+}
+
+// Decode implements Codec.Decode.
+func (y *YAML) Decode(data []byte, config interface{}) error {
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	data, err := json.Marshal(jsonify(doc))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, config)
+}
+
+// DecodeRaw implements RawCodec.DecodeRaw. Numbers are decoded as ints and
+// floats by the yaml package.
+func (y *YAML) DecodeRaw(data []byte) (map[string]interface{}, error) {
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	raw, ok := jsonify(doc).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("yaml: document is not a mapping")
+	}
+	return raw, nil
+}
+
+// jsonify converts maps with interface keys produced by the yaml package
+// at any depth in v to maps with string keys that can be marshaled to JSON.
+func jsonify(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for key, val := range t {
+			m[fmt.Sprint(key)] = jsonify(val)
+		}
+		return m
+	case []interface{}:
+		for i := 0; i < len(t); i++ {
+			t[i] = jsonify(t[i])
+		}
+		return t
+	default:
+		return v
+	}
+}
+
+// init registers the Filter on package initialization in the filter registry.
+func init() {
+	c := &YAML{}
+	codec.Register("yaml", c)
+	codec.Register("yml", c)
+}
@@ -5,6 +5,8 @@
 package config
 
 import (
+	"bytes"
+	"encoding"
 	"fmt"
 	"reflect"
 	"sort"
@@ -32,7 +34,9 @@ func CompareInterfaces(a, b interface{}) int {
 // For structs, only published fields are enumerated and compared. Private
 // fields do not affect comparison. A struct with less public fields returns a
 // less result. Structs with equal number of fields are compared alphabetically
-// ascending comparing field value kinds, names and finally values.
+// ascending comparing field value kinds, names and finally values. Structs of
+// the same type that implement encoding.TextMarshaler, such as Secret, are
+// compared by their marshaled text instead.
 //
 // Comparisons between two Arrays and/or slices return a less result for values
 // with less dimensions. In equal dimensioned arrays or slices bytes are
@@ -73,6 +77,10 @@ func CompareValues(a, b reflect.Value) int {
 	if bpd > apd {
 		return -1
 	}
+	// Compare kinds of dereferenced values, i.e. nil pointers.
+	if res := compareKind(a.Kind(), b.Kind()); res != 0 {
+		return res
+	}
 	// Compare by Kind.
 	switch a.Kind() {
 	case reflect.Bool:
@@ -156,16 +164,16 @@ func CompareValues(a, b reflect.Value) int {
 		akeys := a.MapKeys()
 		bkeys := b.MapKeys()
 		sort.Slice(akeys, func(i, j int) bool {
-			return akeys[i].String() < akeys[j].String()
+			return fmt.Sprint(akeys[i]) < fmt.Sprint(akeys[j])
 		})
 		sort.Slice(bkeys, func(i, j int) bool {
-			return bkeys[i].String() < bkeys[j].String()
+			return fmt.Sprint(bkeys[i]) < fmt.Sprint(bkeys[j])
 		})
 		for i := 0; i < len(akeys); i++ {
 			if res := compareKind(akeys[i].Kind(), bkeys[i].Kind()); res != 0 {
 				return res
 			}
-			if res := strings.Compare(akeys[i].String(), bkeys[i].String()); res != 0 {
+			if res := strings.Compare(fmt.Sprint(akeys[i]), fmt.Sprint(bkeys[i])); res != 0 {
 				return res
 			}
 		}
@@ -173,29 +181,37 @@ func CompareValues(a, b reflect.Value) int {
 		for i := 0; i < len(akeys); i++ {
 			aval := a.MapIndex(akeys[i])
 			bval := b.MapIndex(bkeys[i])
-			if a.Kind() != b.Kind() {
-				if res := compareKind(aval.Kind(), bval.Kind()); res != 0 {
-					return res
-				}
-				if res := CompareValues(aval, bval); res != 0 {
-					return res
-				}
+			if res := compareKind(aval.Kind(), bval.Kind()); res != 0 {
+				return res
+			}
+			if res := CompareValues(aval, bval); res != 0 {
+				return res
 			}
 		}
 	case reflect.String:
 		return strings.Compare(a.String(), b.String())
 	case reflect.Struct:
+		// Compare text of TextMarshalers, e.g. structs with private fields.
+		if a.Type() == b.Type() && a.CanInterface() && b.CanInterface() {
+			if am, ok := a.Interface().(encoding.TextMarshaler); ok {
+				at, aerr := am.MarshalText()
+				bt, berr := b.Interface().(encoding.TextMarshaler).MarshalText()
+				if aerr == nil && berr == nil {
+					return bytes.Compare(at, bt)
+				}
+			}
+		}
 		// Enum public fields.
 		aflds := make([]reflect.StructField, 0, a.NumField())
 		bflds := make([]reflect.StructField, 0, b.NumField())
 		for i := 0; i < a.NumField(); i++ {
-			if !a.Field(i).CanSet() {
+			if a.Type().Field(i).PkgPath != "" {
 				continue
 			}
 			aflds = append(aflds, a.Type().Field(i))
 		}
 		for i := 0; i < b.NumField(); i++ {
-			if !b.Field(i).CanSet() {
+			if b.Type().Field(i).PkgPath != "" {
 				continue
 			}
 			bflds = append(bflds, b.Type().Field(i))
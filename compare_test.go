@@ -0,0 +1,34 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package config
+
+import "testing"
+
+func TestCompareInterfaces(t *testing.T) {
+	type Server struct {
+		Host string
+	}
+	type Config struct {
+		Labels  map[int]string
+		Servers map[string]Server
+		Primary *Server
+	}
+	tests := []struct {
+		a, b *Config
+		res  int
+	}{
+		{&Config{}, &Config{}, 0},
+		{&Config{Labels: map[int]string{1: "a", 2: "b"}}, &Config{Labels: map[int]string{2: "b", 1: "a"}}, 0},
+		{&Config{Labels: map[int]string{1: "a"}}, &Config{Labels: map[int]string{1: "b"}}, -1},
+		{&Config{Labels: map[int]string{2: "a"}}, &Config{Labels: map[int]string{1: "a"}}, 1},
+		{&Config{Servers: map[string]Server{"x": {"a"}}}, &Config{Servers: map[string]Server{"x": {"b"}}}, -1},
+		{&Config{Primary: &Server{}}, &Config{}, 1},
+	}
+	for i, test := range tests {
+		if res := CompareInterfaces(test.a, test.b); res != test.res {
+			t.Fatalf("CompareInterfaces failed (%d): expected %d, got %d", i, test.res, res)
+		}
+	}
+}
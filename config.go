@@ -12,9 +12,12 @@
 package config
 
 import (
+	"bytes"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"runtime"
 
 	"github.com/vedranvuk/config/codec"
@@ -31,25 +34,91 @@ var (
 	// ErrUnsupportedOS is returned by GetSystemConfigPath and
 	// GetUserConfigPath on an unsupported OS.
 	ErrUnsupportedOS = ErrConfig.WrapFormat("unsupported OS '%s'")
+
+	// ErrDecode is returned when a codec fails to decode a config document.
+	// It wraps the codec error.
+	ErrDecode = ErrConfig.Wrap("decode failed")
 )
 
 // WriteConfigFile writes config to a file specified by filename.
 // Codec is selected from filename extension and must be registered.
 // WriteConfigFile registers all Interface types in config at any depth.
+//
+// File is written atomically by writing config to a temporary file in the
+// same directory, syncing it to disk and renaming it to filename so that a
+// failed write never leaves a truncated file. Permissions and, where
+// supported, ownership of an existing file are preserved. New files are
+// created with 0644 permissions. If filename is a symbolic link the file it
+// points to is written and the link is kept.
+//
 // If an error occurs it is returned.
 func WriteConfigFile(filename string, config interface{}) error {
+	return writeConfigFile(filename, config, false)
+}
+
+// WriteConfigFileBackup is like WriteConfigFile but keeps a copy of the
+// previous version of the file, if it exists, named as filename with
+// BackupSuffix appended. ReadConfigFile falls back to the backup if the file
+// fails to decode, see ErrBackupRead.
+func WriteConfigFileBackup(filename string, config interface{}) error {
+	return writeConfigFile(filename, config, true)
+}
+
+// writeConfigFile is the implementation of WriteConfigFile. If backup is
+// specified the previous version of the file is backed up. If filename is a
+// symbolic link its target is written and backed up.
+func writeConfigFile(filename string, config interface{}, backup bool) error {
+	target := linkTarget(filename)
+	fi, err := os.Stat(target)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if backup && fi != nil {
+		if err := writeFileAtomic(target+BackupSuffix, fi, func(w io.Writer) error {
+			file, err := os.Open(target)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			_, err = io.Copy(w, file)
+			return err
+		}); err != nil {
+			return err
+		}
+	}
+	return writeFileAtomic(target, fi, func(w io.Writer) error {
+		return WriteConfig(w, ext(filename), config)
+	})
+}
+
+// WriteConfig writes config to w using a codec registered under ext which is
+// a filename extension without the dot, e.g. "json".
+//
+// If the codec implements codec.StreamCodec config is encoded directly to w,
+// otherwise it is encoded to a buffer first then written to w.
+//
+// WriteConfig registers all Interface types in config at any depth and writes
+// the schema version field of config as the current version if it is not
+// set, without modifying config. See VersionKey.
+// If an error occurs it is returned.
+func WriteConfig(w io.Writer, ext string, config interface{}) error {
 	if err := RegisterInterfaces(config); err != nil {
 		return err
 	}
-	c, err := codec.Get(ext(filename))
+	config = stampVersion(config)
+	c, err := codec.Get(ext)
 	if err != nil {
 		return err
 	}
+	if sc, ok := c.(codec.StreamCodec); ok {
+		return sc.EncodeTo(w, config)
+	}
 	data, err := c.Encode(config)
 	if err != nil {
 		return err
 	}
-	return ioutil.WriteFile(filename, data, 0644)
+	_, err = w.Write(data)
+	return err
 }
 
 // ReadConfigFile reads a configuration file specified by filename into
@@ -68,19 +137,122 @@ func WriteConfigFile(filename string, config interface{}) error {
 // initialized properly. Types are registered automatically when using
 // WriteConfigFile and can be manually registered using RegisterType.
 //
+// Config files may include other config files, see IncludeKey, and are
+// migrated to SchemaVersion after includes are expanded, see Migrate. Config
+// is not interpolated, see ReadConfigFileInterpolated.
+//
+// If the file fails to decode and a backup of the file written by
+// WriteConfigFileBackup exists, config is restored to its state prior to the
+// call and the backup is read instead, in which case an ErrBackupRead is
+// returned. Other errors, such as those of includes, migrations or Secrets,
+// do not fall back to the backup.
+//
 // If an error occurs it is returned.
 func ReadConfigFile(filename string, config interface{}) error {
+	return readFile(filename, config, true)
+}
+
+// readFile is the implementation of ReadConfigFile. If migrate is not
+// specified the file is not migrated.
+func readFile(filename string, config interface{}, migrate bool) error {
+	return readFileWith(filename, config, migrate, func(r io.Reader, c codec.Codec) error {
+		return decodeConfig(r, c, config)
+	})
+}
+
+// readFileWith reads a config file specified by filename into config using
+// read which is called with a reader of the document with includes expanded
+// and, if migrate is specified, migrated and the codec of the file.
+//
+// Migrations run once per read; if the file was migrated its backup is not
+// read when read fails. See readConfigFile.
+func readFileWith(filename string, config interface{}, migrate bool, read func(r io.Reader, c codec.Codec) error) error {
 	c, err := codec.Get(ext(filename))
 	if err != nil {
 		return err
 	}
-	data, err := ioutil.ReadFile(filename)
+	migrated := false
+	return readConfigFile(filename, config, func(r io.Reader) error {
+		if migrated {
+			return errNoBackup
+		}
+		r, err := includeReader(filename, r, c)
+		if err != nil {
+			return err
+		}
+		if migrate {
+			if r, migrated, err = migrateReader(r, c); err != nil {
+				migrated = true
+				return err
+			}
+		}
+		return read(r, c)
+	})
+}
+
+// ReadConfig reads a configuration from r into config using a codec
+// registered under ext which is a filename extension without the dot, e.g.
+// "json". See ReadConfigFile for details on config and Interface handling.
+//
+// If the codec implements codec.StreamCodec config is decoded directly from r
+// while retaining read data for a possible second pass required to initialize
+// Interfaces, otherwise r is read fully before decoding.
+//
+// If an error occurs it is returned.
+func ReadConfig(r io.Reader, ext string, config interface{}) error {
+	c, err := codec.Get(ext)
+	if err != nil {
+		return err
+	}
+	return readConfig(r, c, config)
+}
+
+// readConfig is the implementation of ReadConfig.
+func readConfig(r io.Reader, c codec.Codec, config interface{}) error {
+	r, _, err := migrateReader(r, c)
 	if err != nil {
 		return err
 	}
-	if err := c.Decode(data, config); err != nil {
+	return decodeConfig(r, c, config)
+}
+
+// decodeConfig decodes a config document read from r into config using codec
+// c, initializes Interfaces and resolves Secrets in config.
+func decodeConfig(r io.Reader, c codec.Codec, config interface{}) error {
+	if err := decodeInterfaces(r, c, config); err != nil {
 		return err
 	}
+	return resolveSecrets(reflect.ValueOf(config))
+}
+
+// decodeInterfaces decodes a config document read from r into config using
+// codec c and decodes it again if any Interfaces were initialized.
+func decodeInterfaces(r io.Reader, c codec.Codec, config interface{}) error {
+	sc, ok := c.(codec.StreamCodec)
+	if !ok {
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		if err := c.Decode(data, config); err != nil {
+			return ErrDecode.WrapCause("", err)
+		}
+		needsreload, err := InitializeInterfaces(config)
+		if err != nil {
+			return err
+		}
+		if !needsreload {
+			return nil
+		}
+		if err := c.Decode(data, config); err != nil {
+			return ErrDecode.WrapCause("", err)
+		}
+		return nil
+	}
+	buf := bytes.NewBuffer(nil)
+	if err := sc.DecodeFrom(io.TeeReader(r, buf), config); err != nil {
+		return ErrDecode.WrapCause("", err)
+	}
 	needsreload, err := InitializeInterfaces(config)
 	if err != nil {
 		return err
@@ -88,7 +260,10 @@ func ReadConfigFile(filename string, config interface{}) error {
 	if !needsreload {
 		return nil
 	}
-	return c.Decode(data, config)
+	if err := sc.DecodeFrom(buf, config); err != nil {
+		return ErrDecode.WrapCause("", err)
+	}
+	return nil
 }
 
 // ext is a helper that extracts the extension from the filename, without the
@@ -105,7 +280,8 @@ func ext(filename string) (s string) {
 }
 
 // GetSystemConfigPath returns the path to the base system configuration
-// directory that depends on the running OS and is defined as follows:
+// directory to which system configuration is written, which is the last path
+// returned by GetSystemConfigPaths, and is defined as follows:
 //
 // darwin:             "/private/etc"
 // unix, linux et al:  "/etc"
@@ -113,30 +289,18 @@ func ext(filename string) (s string) {
 //
 // If an unsupported OS is detected returns empty path and ErrUnsupportedOS.
 func GetSystemConfigPath() (path string, err error) {
-	switch runtime.GOOS {
-	case "darwin":
-		path = "/private/etc"
-	case "aix", "android", "dragonfly", "freebsd", "illumos", "linux", "netbsd",
-		"openbsd", "plan9", "solaris":
-		path = "/etc"
-	case "windows":
-		path = os.ExpandEnv("$ALLUSERSPROFILE")
-	case "js":
-		fallthrough
-	default:
-		return "", ErrUnsupportedOS.WrapArgs(runtime.GOOS)
-	}
+	paths, err := GetSystemConfigPaths()
 	if err != nil {
 		return "", err
 	}
-	return
+	return paths[len(paths)-1], nil
 }
 
 // GetUserConfigPath returns the path to the base user configuration
 // directory that depends on the running OS and is defined as follows:
 //
-// darwin:             "$HOME"
-// unix, linux et al:  "$HOME"
+// darwin:             "$HOME/.config"
+// unix, linux et al:  "$XDG_CONFIG_HOME" or "$HOME/.config"
 // windows:            "%USERPROFILE%"
 //
 // If an unsupported OS is detected returns empty path and ErrUnsupportedOS.
@@ -146,7 +310,7 @@ func GetUserConfigPath() (path string, err error) {
 		path = filepath.Join(os.ExpandEnv("$HOME"), ".config")
 	case "aix", "android", "dragonfly", "freebsd", "illumos", "linux", "netbsd",
 		"openbsd", "plan9", "solaris":
-		path = filepath.Join(os.ExpandEnv("$HOME"), ".config")
+		path = xdgPath("XDG_CONFIG_HOME", filepath.Join(os.ExpandEnv("$HOME"), ".config"))
 	case "windows":
 		path = os.ExpandEnv("$USERPROFILE")
 	case "js":
@@ -160,7 +324,26 @@ func GetUserConfigPath() (path string, err error) {
 	return
 }
 
-// GetProgramConfigPath returns path to the directory of the executable.
+// GetProgramConfigPath returns path to the directory of the executable with
+// symbolic links evaluated. If the path of the executable cannot be resolved
+// the directory of os.Args[0] is returned.
 func GetProgramConfigPath() string {
-	return filepath.Dir(os.Args[0])
+	path, err := programPath()
+	if err != nil {
+		return filepath.Dir(os.Args[0])
+	}
+	return path
+}
+
+// programPath returns path to the directory of the executable with symbolic
+// links evaluated or an ErrProgramDir if it cannot be resolved.
+func programPath() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", ErrProgramDir.WrapCause("", err)
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return "", ErrProgramDir.WrapCause("", err)
+	}
+	return filepath.Dir(exe), nil
 }
@@ -5,17 +5,24 @@
 package config
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"reflect"
 	"testing"
 
 	"github.com/vedranvuk/config/codec"
 
+	_ "github.com/vedranvuk/config/codec/env"
 	_ "github.com/vedranvuk/config/codec/gob"
+	_ "github.com/vedranvuk/config/codec/hcl"
+	_ "github.com/vedranvuk/config/codec/ini"
 	_ "github.com/vedranvuk/config/codec/json"
+	_ "github.com/vedranvuk/config/codec/toml"
 	_ "github.com/vedranvuk/config/codec/xml"
+	_ "github.com/vedranvuk/config/codec/yaml"
 )
 
 func TestPaths(t *testing.T) {
@@ -46,6 +53,30 @@ func TestReadWriteConfigFile(t *testing.T) {
 	if err := readwriteconfig("gob"); err != nil {
 		t.Fatal(err)
 	}
+	if err := readwriteconfig("yaml"); err != nil {
+		t.Fatal(err)
+	}
+	if err := readwriteconfig("yml"); err != nil {
+		t.Fatal(err)
+	}
+	if err := readwriteconfig("toml"); err != nil {
+		t.Fatal(err)
+	}
+	if err := readwriteconfig("ini"); err != nil {
+		t.Fatal(err)
+	}
+	if err := readwriteconfig("cfg"); err != nil {
+		t.Fatal(err)
+	}
+	if err := readwriteconfig("env"); err != nil {
+		t.Fatal(err)
+	}
+	if err := readwriteconfig("properties"); err != nil {
+		t.Fatal(err)
+	}
+	if err := readwriteconfig("hcl"); err != nil {
+		t.Fatal(err)
+	}
 	if err := readwriteconfig("INVALIDCODEC"); err != nil {
 		if !errors.Is(err, codec.ErrCodecNotRegistered) {
 			t.Fatal(err)
@@ -75,3 +106,283 @@ func readwriteconfig(codec string) error {
 	}
 	return nil
 }
+
+func TestReadWriteConfig(t *testing.T) {
+	for _, codec := range []string{"json", "xml", "gob"} {
+		if err := readwritestream(codec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := readwritestream("INVALIDCODEC"); err != nil {
+		if !errors.Is(err, codec.ErrCodecNotRegistered) {
+			t.Fatal(err)
+		}
+	}
+}
+
+func readwritestream(codec string) error {
+	type TestConfig struct {
+		Name  string
+		Age   int
+		Truth *bool
+	}
+	t := true
+	out := &TestConfig{"Foo", 42, &t}
+	buf := bytes.NewBuffer(nil)
+	if err := WriteConfig(buf, codec, out); err != nil {
+		return err
+	}
+	in := &TestConfig{}
+	if err := ReadConfig(buf, codec, in); err != nil {
+		return err
+	}
+	if !reflect.DeepEqual(in, out) {
+		return errors.New("TestReadWriteConfig failed: in and out not equal")
+	}
+	return nil
+}
+
+func TestReadWriteConfigFileInterface(t *testing.T) {
+	for _, codec := range []string{"json", "yaml", "toml", "ini", "env", "properties", "hcl"} {
+		if err := readwriteinterface(codec); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+type testInterfaceData struct {
+	Name string
+	Age  int
+}
+
+func readwriteinterface(codec string) error {
+	type TestConfig struct {
+		Name string
+		Data Interface
+	}
+	filename := "testinterface." + codec
+	out := &TestConfig{"Foo", Interface{Value: &testInterfaceData{"Bar", 42}}}
+	if err := WriteConfigFile(filename, out); err != nil {
+		return err
+	}
+	defer os.Remove(filename)
+	in := &TestConfig{}
+	if err := ReadConfigFile(filename, in); err != nil {
+		return err
+	}
+	if !reflect.DeepEqual(in, out) {
+		return fmt.Errorf("TestReadWriteConfigFileInterface failed for %s: in and out not equal", codec)
+	}
+	return nil
+}
+
+func TestReadWriteConfigFileNested(t *testing.T) {
+	type Server struct {
+		Host string
+		Port int
+	}
+	type TestConfig struct {
+		Name     string
+		Database struct {
+			User    string
+			Timeout float64
+		}
+		Servers []Server
+	}
+	for _, codec := range []string{"json", "yaml", "toml", "hcl"} {
+		filename := "testnested." + codec
+		out := &TestConfig{Name: "Foo", Servers: []Server{{"alpha", 80}, {"beta", 443}}}
+		out.Database.User = "admin"
+		out.Database.Timeout = 1.5
+		if err := WriteConfigFile(filename, out); err != nil {
+			t.Fatal(err)
+		}
+		in := &TestConfig{}
+		err := ReadConfigFile(filename, in)
+		os.Remove(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(in, out) {
+			t.Fatalf("TestReadWriteConfigFileNested failed for %s: in and out not equal", codec)
+		}
+	}
+}
+
+func TestReadConfigFileINI(t *testing.T) {
+	type TestConfig struct {
+		Name     string
+		Tags     []string
+		Database struct {
+			User    string
+			Port    *int
+			Replica struct {
+				Host string
+			}
+		}
+		Labels map[string]int
+	}
+	const data = `; comment
+name = Foo
+Tags = a, b
+
+[Database]
+# comment
+User = " admin "
+Port = 5432
+
+[database.replica]
+Host = backup
+
+[Labels]
+x = 1
+`
+	filename := "testini.ini"
+	if err := ioutil.WriteFile(filename, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(filename)
+	in := &TestConfig{}
+	if err := ReadConfigFile(filename, in); err != nil {
+		t.Fatal(err)
+	}
+	port := 5432
+	out := &TestConfig{Name: "Foo", Tags: []string{"a", "b"}, Labels: map[string]int{"x": 1}}
+	out.Database.User = " admin "
+	out.Database.Port = &port
+	out.Database.Replica.Host = "backup"
+	if !reflect.DeepEqual(in, out) {
+		t.Fatalf("TestReadConfigFileINI failed: in and out not equal: %#v", in)
+	}
+}
+
+func TestReadWriteConfigFileEnv(t *testing.T) {
+	type TestConfig struct {
+		Name     string
+		Database struct {
+			User     string
+			Password string
+			Hosts    []string
+		}
+		Labels map[string]string
+	}
+	out := &TestConfig{Name: "Foo", Labels: map[string]string{"tier": "web app"}}
+	out.Database.User = "admin"
+	out.Database.Password = "it's\n$ecret"
+	out.Database.Hosts = []string{"alpha", "beta"}
+	const expected = `NAME=Foo
+DATABASE_USER=admin
+DATABASE_PASSWORD='it'\''s
+$ecret'
+DATABASE_HOSTS=alpha,beta
+LABELS_tier='web app'
+`
+	for _, codec := range []string{"env", "properties"} {
+		filename := "testenv." + codec
+		if err := WriteConfigFile(filename, out); err != nil {
+			t.Fatal(err)
+		}
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if codec == "env" && string(data) != expected {
+			t.Fatalf("TestReadWriteConfigFileEnv failed: unexpected output:\n%s", data)
+		}
+		in := &TestConfig{}
+		err = ReadConfigFile(filename, in)
+		os.Remove(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(in, out) {
+			t.Fatalf("TestReadWriteConfigFileEnv failed for %s: in and out not equal", codec)
+		}
+	}
+}
+
+func TestReadWriteConfigFileLists(t *testing.T) {
+	type TestConfig struct {
+		Hosts []string
+		Empty []string
+		Ports []int
+	}
+	out := &TestConfig{
+		Hosts: []string{"a,b", " c ", "", `"d"`, `e\`},
+		Empty: []string{},
+		Ports: []int{80, 443},
+	}
+	for _, codec := range []string{"ini", "env", "properties"} {
+		filename := "testlists." + codec
+		if err := WriteConfigFile(filename, out); err != nil {
+			t.Fatal(err)
+		}
+		in := &TestConfig{}
+		err := ReadConfigFile(filename, in)
+		os.Remove(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(in, out) {
+			t.Fatalf("TestReadWriteConfigFileLists failed for %s: %#v", codec, in)
+		}
+	}
+}
+
+func TestReadConfigFileHCL(t *testing.T) {
+	type Listener struct {
+		Name    string `hcl:",key"`
+		Address string
+		Port    uint16 `config:"range=1:65535;default=8080"`
+	}
+	type TestConfig struct {
+		Name     string `config:"default=app"`
+		Database struct {
+			User string
+		}
+		Listeners []Listener
+		Limits    map[string]int
+	}
+	const data = `
+database {
+  user = "admin"
+}
+
+listeners "public" {
+  address = "0.0.0.0"
+}
+
+listeners "private" {
+  address = "127.0.0.1"
+  port = 8443
+}
+
+limits {
+  "conn" = 10
+}
+`
+	filename := "testhcl.hcl"
+	if err := ioutil.WriteFile(filename, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(filename)
+	in := &TestConfig{Listeners: []Listener{{Name: "stale"}}}
+	if err := ReadConfigFile(filename, in); err != nil {
+		t.Fatal(err)
+	}
+	if err := Default(in, false); err != nil && !errors.Is(err, ErrWarning) {
+		t.Fatal(err)
+	}
+	out := &TestConfig{
+		Name: "app",
+		Listeners: []Listener{
+			{"public", "0.0.0.0", 8080},
+			{"private", "127.0.0.1", 8443},
+		},
+		Limits: map[string]int{"conn": 10},
+	}
+	out.Database.User = "admin"
+	if !reflect.DeepEqual(in, out) {
+		t.Fatalf("TestReadConfigFileHCL failed: in and out not equal: %#v", in)
+	}
+}
@@ -8,7 +8,6 @@ import (
 	"errors"
 	"os"
 	"path/filepath"
-	"runtime"
 )
 
 var (
@@ -16,11 +15,14 @@ var (
 	ErrNoConfigLoaded = ErrConfig.Wrap("no configuration files loaded")
 
 	// ErrProgramDir is returned when trying to write or read from
-	// a program directory on an platform that does not support it.
-	// i.e., NOT Windows.
-	ErrProgramDir = ErrConfig.Wrap("program directory configuration not supported on this os")
+	// a program directory whose path cannot be resolved on the platform.
+	ErrProgramDir = ErrConfig.Wrap("program directory cannot be resolved")
 )
 
+// PortableMarker is the name of the file which, if it exists in the program
+// directory, enables portable mode of Dir. See NewDir.
+const PortableMarker = ".portable"
+
 // Dir is a helper that represents a configuration directory in multiple
 // locations defined by priorities, namely: System, User and Local/Executable
 // level.
@@ -28,10 +30,25 @@ var (
 // A Dir takes a prefix which defines a subdirectory in either of configuration
 // locations. If prefix is a path it is rooted at either configuration location
 // being accessed.
+//
+// System configuration location may consist of multiple directories, such as
+// those listed in XDG_CONFIG_DIRS on unix systems, which are searched in order
+// of descending priority. Dir also resolves user data, cache, state and
+// runtime directories and system data directories with the same prefix.
+// Those are not created until a file is saved to them.
 type Dir struct {
-	prefix string // prefix is the configuration prefix.
-	sysdir string // sysdir is the system location of Dir.
-	usrdir string // usrdir is the user location of Dir.
+	prefix   string   // prefix is the configuration prefix.
+	sysdirs  []string // sysdirs are system locations of Dir by descending priority.
+	sysdir   string   // sysdir is the system location system configs are saved to.
+	usrdir   string   // usrdir is the user location of Dir.
+	datadirs []string // datadirs are system data locations of Dir by descending priority.
+	usrdata  string   // usrdata is the user data location of Dir.
+	cachedir string   // cachedir is the user cache location of Dir.
+	statedir string   // statedir is the user state location of Dir.
+	rundir   string   // rundir is the user runtime location of Dir.
+	roots    []Root   // roots are configuration search roots by ascending priority.
+	progdir  string   // progdir is the program directory, if resolved.
+	portable bool     // portable is true if Dir is in portable mode.
 }
 
 // NewDir returns a new Dir with the given prefix or an error.
@@ -39,8 +56,21 @@ type Dir struct {
 // Prefix represents the name of the directory to be read/written in any of
 // locations Dir recognizes. It can be a directory name or a path in case of
 // which it will be rooted at all configuration locations.
+//
+// Roots of Dir in order of descending priority are the user configuration
+// directory, system configuration directories in order returned by
+// GetSystemConfigPaths and the program directory, if resolved, which is
+// read-only and serves as a fallback for configs shipped with the program.
+//
+// If a file named PortableMarker exists in the program directory Dir is in
+// portable mode in which the user configuration location is the program
+// directory and user data, cache and state locations are "data", "cache" and
+// "state" subdirectories of the program directory, without the prefix, so
+// that a program and its files can be moved together. Runtime location is
+// not redirected as it is tied to the user session. System locations are
+// searched as usual.
 func NewDir(prefix string) (*Dir, error) {
-	sys, err := GetSystemConfigPath()
+	sys, err := GetSystemConfigPaths()
 	if err != nil {
 		return nil, err
 	}
@@ -48,23 +78,79 @@ func NewDir(prefix string) (*Dir, error) {
 	if err != nil {
 		return nil, err
 	}
-	p := &Dir{
-		prefix: prefix,
-		sysdir: filepath.Join(sys, prefix),
-		usrdir: filepath.Join(usr, prefix),
+	p, err := newDir(prefix)
+	if err != nil {
+		return nil, err
+	}
+	p.sysdirs = joinPaths(sys, prefix)
+	p.sysdir = p.sysdirs[len(p.sysdirs)-1]
+	p.usrdir = filepath.Join(usr, prefix)
+	if p.progdir != "" {
+		if _, err := os.Stat(filepath.Join(p.progdir, PortableMarker)); err == nil {
+			p.portable = true
+			p.usrdir = p.progdir
+			p.usrdata = filepath.Join(p.progdir, "data")
+			p.cachedir = filepath.Join(p.progdir, "cache")
+			p.statedir = filepath.Join(p.progdir, "state")
+		}
+	}
+	if !p.portable && p.progdir != "" {
+		p.roots = append(p.roots, Root{Path: p.progdir, ReadOnly: true})
+	}
+	for i := len(p.sysdirs) - 1; i >= 0; i-- {
+		p.roots = append(p.roots, Root{Path: p.sysdirs[i]})
 	}
+	p.roots = append(p.roots, Root{Path: p.usrdir})
 	if err := os.MkdirAll(p.usrdir, 0755); err != nil {
 		return nil, err
 	}
 	return p, nil
 }
 
-// LoadSystemConfig loads the config specified by name from the system config
-// directory. See LoadConfig for details.
+// newDir returns a new Dir with the given prefix and resolved data, cache,
+// state and runtime locations but without configuration locations.
+func newDir(prefix string) (*Dir, error) {
+	data, err := GetSystemDataPaths()
+	if err != nil {
+		return nil, err
+	}
+	usrdata, err := GetUserDataPath()
+	if err != nil {
+		return nil, err
+	}
+	cache, err := GetUserCachePath()
+	if err != nil {
+		return nil, err
+	}
+	state, err := GetUserStatePath()
+	if err != nil {
+		return nil, err
+	}
+	run, err := GetUserRuntimePath()
+	if err != nil {
+		return nil, err
+	}
+	prog, _ := programPath()
+	return &Dir{
+		prefix:   prefix,
+		datadirs: joinPaths(data, prefix),
+		usrdata:  filepath.Join(usrdata, prefix),
+		cachedir: filepath.Join(cache, prefix),
+		statedir: filepath.Join(state, prefix),
+		rundir:   filepath.Join(run, prefix),
+		progdir:  prog,
+	}, nil
+}
+
+// LoadSystemConfig loads the config specified by name from the first system
+// config directory in which it exists. See LoadConfig for details.
 //
 // If an error occurs it is returned.
 func (d *Dir) LoadSystemConfig(name string, out interface{}) error {
-	return ReadConfigFile(filepath.Join(d.sysdir, name), out)
+	if len(d.sysdirs) == 0 {
+		return ErrNoRoot
+	}
+	return ReadConfigFile(d.systemPath(name), out)
 }
 
 // LoadUserConfig loads the config specified by name from the user config
@@ -78,23 +164,24 @@ func (d *Dir) LoadUserConfig(name string, out interface{}) error {
 // LoadProgramConfig loads the config specified by name from the program
 // directory. See LoadConfig for details.
 //
-// Loading configuration from program directory is supported on windows only.
-//
+// If the program directory cannot be resolved an ErrProgramDir is returned.
 // If an error occurs it is returned.
 func (d *Dir) LoadProgramConfig(name string, out interface{}) error {
-	if runtime.GOOS != "windows" {
+	if d.progdir == "" {
 		return ErrProgramDir
 	}
-	path := filepath.Join(GetProgramConfigPath(), name)
-	return ReadConfigFile(path, out)
+	return ReadConfigFile(filepath.Join(d.progdir, name), out)
 }
 
 // LoadConfig searches for and loads configuration file specified by name in the
-// following locations:
+// roots of Dir which by default are the following locations:
 //
-// program directory (windows only)
 // user configuration directory
-// system configuration directory
+// system configuration directories, in order
+// program directory
+//
+// In portable mode the program directory is the user configuration directory.
+// Roots can be specified using NewDirRoots or added using AddRoots.
 //
 // File is read into out which must be a non-nil pointer to a variable
 // compatible with config file being loaded.
@@ -103,8 +190,10 @@ func (d *Dir) LoadProgramConfig(name string, out interface{}) error {
 // loaded.
 //
 // If override is specified all found config files from all locations are
-// loaded in reverse order described above with config files loaded later
-// overriding any values loaded to out thus far.
+// loaded in reverse order described above, each into a new value that is
+// merged into out using Merge, with config files loaded later overriding any
+// values loaded to out thus far. Merge strategy of each field can be defined
+// by the merge key in the config tag.
 //
 // If a config file with the specified name is not found in any locations an
 // ErrNoConfigLoaded is returned.
@@ -117,62 +206,119 @@ func (d *Dir) LoadProgramConfig(name string, out interface{}) error {
 // configuration directory.
 //
 func (d *Dir) LoadConfig(name string, override bool, out interface{}) (err error) {
-	if override {
-		loaded := false
-		if err = d.LoadSystemConfig(name, out); err != nil {
-			if !errors.Is(err, os.ErrNotExist) {
-				return err
-			}
-		} else {
-			loaded = true
+	return d.loadConfig(name, override, out, nil)
+}
+
+// LoadConfigProvenance is like LoadConfig but also returns a Provenance that
+// records the path of the file that last set the value of each field at any
+// depth in out, suitable for reporting effective configuration with origins.
+// Fields present in a file are attributed to it even if their values did not
+// change. See ReadConfigFilePresence.
+//
+// If defaults is specified out is defaulted using DefaultsSource before any
+// files are loaded and fields set from default keys in config tags are
+// recorded as set by "defaults".
+//
+// If an error occurs it is returned along with Provenance of files loaded so
+// far.
+func (d *Dir) LoadConfigProvenance(name string, override, defaults bool, out interface{}) (Provenance, error) {
+	prov := make(Provenance)
+	if defaults {
+		source := DefaultsSource()
+		if err := prov.load(source.Name(), out, source.Load); err != nil {
+			return prov, err
 		}
-		if err = d.LoadUserConfig(name, out); err != nil {
-			if !errors.Is(err, os.ErrNotExist) {
-				return err
+	}
+	return prov, d.loadConfig(name, override, out, prov)
+}
+
+// loadConfig is the implementation of LoadConfig. If prov is not nil paths of
+// loaded files are recorded in it.
+func (d *Dir) loadConfig(name string, override bool, out interface{}, prov Provenance) error {
+	return loadFiles(d.configPaths(name), override, out, prov)
+}
+
+// loadFiles loads config files at paths specified in order of ascending
+// priority into out as described in LoadConfig. Missing files are skipped. If
+// prov is not nil paths of loaded files are recorded in it. If a backup of
+// any file was read all files are loaded and the first ErrBackupRead is
+// returned.
+func loadFiles(paths []string, override bool, out interface{}, prov Provenance) error {
+	if !override {
+		for i, j := 0, len(paths)-1; i < j; i, j = i+1, j-1 {
+			paths[i], paths[j] = paths[j], paths[i]
+		}
+	}
+	var warning error
+	loaded := false
+	for _, path := range paths {
+		load := func(config interface{}) (Presence, error) {
+			if override {
+				return mergeConfigFile(path, config, true)
 			}
-		} else {
-			loaded = true
+			if prov == nil {
+				return nil, readFile(path, config, true)
+			}
+			return readFilePresence(path, config, true)
 		}
-		if err = d.LoadProgramConfig(name, out); err != nil {
+		if err := prov.loadPresence(path, out, load); errors.Is(err, ErrBackupRead) {
+			if warning == nil {
+				warning = err
+			}
+		} else if err != nil {
 			if !errors.Is(err, os.ErrNotExist) {
-				if !errors.Is(err, ErrProgramDir) {
-					return err
-				}
+				return err
 			}
-		} else {
-			loaded = true
+			continue
 		}
-		if !loaded {
-			return ErrNoConfigLoaded
+		if loaded = true; !override {
+			break
 		}
-		return nil
 	}
-	loaded := false
-	if err = d.LoadProgramConfig(name, out); err != nil {
-		if !errors.Is(err, os.ErrNotExist) && !errors.Is(err, ErrProgramDir) {
-			return err
-		}
-	} else {
-		loaded = true
+	if !loaded {
+		return ErrNoConfigLoaded
 	}
-	if err = d.LoadUserConfig(name, out); err != nil {
-		if !errors.Is(err, os.ErrNotExist) {
-			return err
-		}
-	} else {
-		loaded = true
+	return warning
+}
+
+// configPaths returns paths to the config file specified by name in all
+// roots of Dir in order of ascending priority.
+func (d *Dir) configPaths(name string) []string {
+	paths := make([]string, 0, len(d.roots))
+	for _, root := range d.roots {
+		paths = append(paths, filepath.Join(root.Path, name))
 	}
-	if err = d.LoadSystemConfig(name, out); err != nil {
-		if !errors.Is(err, os.ErrNotExist) {
-			return err
+	return paths
+}
+
+// systemPath returns the path to the config file specified by name in the
+// first system config directory in which it exists or in the system config
+// directory with the highest priority if it exists in none.
+func (d *Dir) systemPath(name string) string { return findPath(d.sysdirs, name) }
+
+// findPath returns the path to the file specified by name in the first of
+// dirs in which it exists or in the first of dirs if it exists in none. If
+// dirs is empty an empty string is returned.
+func findPath(dirs []string, name string) string {
+	if len(dirs) == 0 {
+		return ""
+	}
+	for _, dir := range dirs {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
 		}
-	} else {
-		loaded = true
 	}
-	if !loaded {
-		return ErrNoConfigLoaded
+	return filepath.Join(dirs[0], name)
+}
+
+// joinPaths returns paths with elem joined to each.
+func joinPaths(paths []string, elem string) []string {
+	result := make([]string, 0, len(paths))
+	for _, path := range paths {
+		result = append(result, filepath.Join(path, elem))
 	}
-	return nil
+	return result
 }
 
 // enforceFilePath creates directories along the assumed path to a file
@@ -182,13 +328,20 @@ func enforceFilePath(filename string) error {
 }
 
 // SaveSystemConfig saves a configuration file defined by name to the system
-// configuration subdirectory defined by Dir prefix. If name contains a path
+// configuration subdirectory defined by Dir prefix in the system configuration
+// directory returned by System. If name contains a path
 // Subdirectories are created if they don't exist.
 //
 // Executable must have permission to write to system locations.
 //
 // If an error occurs it is returned.
 func (d *Dir) SaveSystemConfig(name string, in interface{}) error {
+	if d.sysdir == "" {
+		return ErrNoRoot
+	}
+	if err := d.writable(d.sysdir); err != nil {
+		return err
+	}
 	path := filepath.Join(d.sysdir, name)
 	if err := enforceFilePath(path); err != nil {
 		return err
@@ -200,13 +353,19 @@ func (d *Dir) SaveSystemConfig(name string, in interface{}) error {
 // configuration subdirectory defined by Dir prefix. If name contains a path
 // Subdirectories are created if they don't exist.
 //
+// The file is written while holding an exclusive lock on it so that it does
+// not race with UpdateUserConfig. See LockConfigFile.
+//
 // If an error occurs it is returned.
 func (d *Dir) SaveUserConfig(name string, in interface{}) error {
+	if err := d.writable(d.usrdir); err != nil {
+		return err
+	}
 	path := filepath.Join(d.usrdir, name)
 	if err := enforceFilePath(path); err != nil {
 		return err
 	}
-	return WriteConfigFile(path, in)
+	return WriteConfigFileLocked(path, in)
 }
 
 // SaveProgramConfig saves a configuration file defined by name to the
@@ -216,26 +375,131 @@ func (d *Dir) SaveUserConfig(name string, in interface{}) error {
 // contains a path it is respected and subdirectories are created inside the
 // program directory.
 //
-// Saving to program directory is only supported on Windows.
-//
+// If the program directory cannot be resolved an ErrProgramDir is returned.
 // If an error occurs it is returned.
 func (d *Dir) SaveProgramConfig(name string, in interface{}) error {
-	if runtime.GOOS != "windows" {
+	if d.progdir == "" {
 		return ErrProgramDir
 	}
-	path := filepath.Join(GetProgramConfigPath(), name)
+	path := filepath.Join(d.progdir, name)
 	if err := enforceFilePath(path); err != nil {
-		return nil
+		return err
+	}
+	return WriteConfigFile(path, in)
+}
+
+// LoadData loads the file specified by name from the user data directory or
+// if not found there from the first system data directory in which it exists.
+// See LoadConfig for details on name.
+//
+// If an error occurs it is returned.
+func (d *Dir) LoadData(name string, out interface{}) error {
+	return readFile(findPath(append([]string{d.usrdata}, d.datadirs...), name), out, false)
+}
+
+// SaveData saves the file specified by name to the user data directory. The
+// directory and any subdirectories in name are created if they don't exist.
+//
+// If an error occurs it is returned.
+func (d *Dir) SaveData(name string, in interface{}) error {
+	return saveFile(d.usrdata, name, in, 0755)
+}
+
+// LoadCache loads the file specified by name from the user cache directory.
+// See LoadConfig for details on name.
+//
+// If an error occurs it is returned.
+func (d *Dir) LoadCache(name string, out interface{}) error {
+	return readFile(filepath.Join(d.cachedir, name), out, false)
+}
+
+// SaveCache saves the file specified by name to the user cache directory. The
+// directory and any subdirectories in name are created if they don't exist.
+//
+// If an error occurs it is returned.
+func (d *Dir) SaveCache(name string, in interface{}) error {
+	return saveFile(d.cachedir, name, in, 0755)
+}
+
+// LoadState loads the file specified by name from the user state directory.
+// See LoadConfig for details on name.
+//
+// If an error occurs it is returned.
+func (d *Dir) LoadState(name string, out interface{}) error {
+	return readFile(filepath.Join(d.statedir, name), out, false)
+}
+
+// SaveState saves the file specified by name to the user state directory. The
+// directory and any subdirectories in name are created if they don't exist.
+//
+// If an error occurs it is returned.
+func (d *Dir) SaveState(name string, in interface{}) error {
+	return saveFile(d.statedir, name, in, 0700)
+}
+
+// LoadRuntime loads the file specified by name from the user runtime
+// directory. See LoadConfig for details on name.
+//
+// If an error occurs it is returned.
+func (d *Dir) LoadRuntime(name string, out interface{}) error {
+	return readFile(filepath.Join(d.rundir, name), out, false)
+}
+
+// SaveRuntime saves the file specified by name to the user runtime directory.
+// The directory and any subdirectories in name are created if they don't
+// exist and are accessible by the owner only.
+//
+// If an error occurs it is returned.
+func (d *Dir) SaveRuntime(name string, in interface{}) error {
+	return saveFile(d.rundir, name, in, 0700)
+}
+
+// saveFile writes in to the file specified by name in dir creating dir and
+// any subdirectories in name with permissions perm if they don't exist.
+func saveFile(dir, name string, in interface{}, perm os.FileMode) error {
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), perm); err != nil {
+		return err
 	}
 	return WriteConfigFile(path, in)
 }
 
+// Program returns the program directory of Dir or an empty string if it
+// cannot be resolved.
+func (d *Dir) Program() string { return d.progdir }
+
+// Portable returns true if Dir is in portable mode. See NewDir.
+func (d *Dir) Portable() bool { return d.portable }
+
 // User returns the user configuration path for Dir.
 func (d *Dir) User() string { return d.usrdir }
 
-// System returns the system configuration path of Dir.
+// System returns the system configuration path of Dir to which system
+// configuration is saved or an empty string if Dir has no system
+// configuration path. It is based on GetSystemConfigPath, or is the system
+// path with the highest priority for a Dir returned by NewDirRoots.
 func (d *Dir) System() string { return d.sysdir }
 
+// SystemDirs returns all system configuration paths of Dir in order of
+// descending priority.
+func (d *Dir) SystemDirs() []string { return append([]string{}, d.sysdirs...) }
+
+// Data returns the user data path of Dir.
+func (d *Dir) Data() string { return d.usrdata }
+
+// SystemDataDirs returns all system data paths of Dir in order of descending
+// priority.
+func (d *Dir) SystemDataDirs() []string { return append([]string{}, d.datadirs...) }
+
+// Cache returns the user cache path of Dir.
+func (d *Dir) Cache() string { return d.cachedir }
+
+// State returns the user state path of Dir.
+func (d *Dir) State() string { return d.statedir }
+
+// Runtime returns the user runtime path of Dir.
+func (d *Dir) Runtime() string { return d.rundir }
+
 // RemoveUser removes Dir's configuration directory from user configuration
 // location.
 func (d *Dir) RemoveUser() error {
@@ -5,9 +5,13 @@
 package config
 
 import (
+	"bytes"
+	"errors"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"reflect"
+	"runtime"
 	"testing"
 )
 
@@ -72,3 +76,340 @@ func TestDirDeep(t *testing.T) {
 		t.Fatal("fail")
 	}
 }
+
+func TestDirProvenance(t *testing.T) {
+	type Config struct {
+		Name string
+		Age  int `config:"default=42"`
+		Tags []string
+	}
+	configdir := "configtest"
+	configname := "config.json"
+	dir, err := NewDir(configdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dir.RemoveUser()
+	filename := filepath.Join(dir.User(), configname)
+	if err := ioutil.WriteFile(filename, []byte(`{"Name": "Foo"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	in := &Config{}
+	prov, err := dir.LoadConfigProvenance(configname, true, true, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, &Config{Name: "Foo", Age: 42}) {
+		t.Fatalf("LoadConfigProvenance failed: %#v", in)
+	}
+	if !reflect.DeepEqual(prov, Provenance{"Name": filename, "Age": "defaults"}) {
+		t.Fatalf("LoadConfigProvenance failed: %v", prov)
+	}
+	buf := bytes.NewBuffer(nil)
+	if err := prov.WriteReport(buf, in); err != nil {
+		t.Fatal(err)
+	}
+	report := "Name = Foo (" + filename + ")\nAge = 42 (defaults)\nTags = [] (unset)\n"
+	if buf.String() != report {
+		t.Fatalf("WriteReport failed: %s", buf.String())
+	}
+	if err := ioutil.WriteFile(filename, []byte(`{"Name": "Foo", "Age": 42}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, override := range []bool{false, true} {
+		in = &Config{}
+		if prov, err = dir.LoadConfigProvenance(configname, override, true, in); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(prov, Provenance{"Name": filename, "Age": filename}) {
+			t.Fatalf("LoadConfigProvenance failed: %v", prov)
+		}
+	}
+}
+
+func TestDirXDG(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("XDG base directories are tested on linux only")
+	}
+	type Config struct {
+		Name string
+		Age  int
+	}
+	root, err := ioutil.TempDir("", "configtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	sys1, sys2 := filepath.Join(root, "sys1"), filepath.Join(root, "sys2")
+	env := map[string]string{
+		"XDG_CONFIG_HOME": filepath.Join(root, "user"),
+		"XDG_CONFIG_DIRS": sys1 + ":relative:" + sys2,
+		"XDG_DATA_HOME":   filepath.Join(root, "data"),
+		"XDG_CACHE_HOME":  "relative",
+		"XDG_STATE_HOME":  filepath.Join(root, "state"),
+	}
+	defer setenv(env)()
+	dir, err := NewDir("configtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sysdirs := []string{
+		filepath.Join(sys1, "configtest"),
+		filepath.Join(sys2, "configtest"),
+		filepath.Join("/etc", "configtest"),
+	}
+	if !reflect.DeepEqual(dir.SystemDirs(), sysdirs) || dir.System() != sysdirs[2] {
+		t.Fatalf("SystemDirs failed: %v, %s", dir.SystemDirs(), dir.System())
+	}
+	if path, err := GetSystemConfigPath(); err != nil || path != "/etc" {
+		t.Fatalf("GetSystemConfigPath failed: %s, %v", path, err)
+	}
+	if dir.User() != filepath.Join(root, "user", "configtest") {
+		t.Fatalf("User failed: %s", dir.User())
+	}
+	if dir.Data() != filepath.Join(root, "data", "configtest") ||
+		dir.State() != filepath.Join(root, "state", "configtest") ||
+		dir.Cache() != filepath.Join(os.Getenv("HOME"), ".cache", "configtest") {
+		t.Fatalf("Data, State or Cache failed: %s, %s, %s", dir.Data(), dir.State(), dir.Cache())
+	}
+	for i, dirname := range sysdirs[:2] {
+		if err := os.MkdirAll(dirname, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := WriteConfigFile(filepath.Join(dirname, "config.json"), &Config{Name: dirname, Age: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	in := &Config{}
+	if err := dir.LoadSystemConfig("config.json", in); err != nil {
+		t.Fatal(err)
+	}
+	if in.Name != sysdirs[0] {
+		t.Fatalf("LoadSystemConfig failed: %#v", in)
+	}
+	if err := os.Remove(filepath.Join(sysdirs[0], "config.json")); err != nil {
+		t.Fatal(err)
+	}
+	in = &Config{}
+	prov, err := NewLoader(dir.SystemSource("config.json")).Load(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path := filepath.Join(sysdirs[1], "config.json"); in.Name != sysdirs[1] || prov.Source("Name") != path {
+		t.Fatalf("SystemSource failed: %#v, %v", in, prov)
+	}
+}
+
+func TestDirData(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("XDG base directories are tested on linux only")
+	}
+	type Data struct {
+		Name string
+	}
+	root, err := ioutil.TempDir("", "configtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	defer setenv(map[string]string{
+		"XDG_DATA_HOME":   filepath.Join(root, "data"),
+		"XDG_DATA_DIRS":   filepath.Join(root, "sysdata"),
+		"XDG_CACHE_HOME":  filepath.Join(root, "cache"),
+		"XDG_STATE_HOME":  filepath.Join(root, "state"),
+		"XDG_RUNTIME_DIR": filepath.Join(root, "run"),
+	})()
+	dir, err := NewDir("configtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{dir.Data(), dir.Cache(), dir.State(), dir.Runtime()} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatalf("NewDir failed: %s created", path)
+		}
+	}
+	sysdata := filepath.Join(root, "sysdata", "configtest")
+	if err := os.MkdirAll(sysdata, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteConfigFile(filepath.Join(sysdata, "data.json"), &Data{"system"}); err != nil {
+		t.Fatal(err)
+	}
+	in := &Data{}
+	if err := dir.LoadData("data.json", in); err != nil || in.Name != "system" {
+		t.Fatalf("LoadData failed: %v, %#v", err, in)
+	}
+	type helpers struct {
+		name string
+		load func(string, interface{}) error
+		save func(string, interface{}) error
+	}
+	for _, h := range []helpers{
+		{"data", dir.LoadData, dir.SaveData},
+		{"cache", dir.LoadCache, dir.SaveCache},
+		{"state", dir.LoadState, dir.SaveState},
+		{"runtime", dir.LoadRuntime, dir.SaveRuntime},
+	} {
+		if err := h.save("sub/data.json", &Data{h.name}); err != nil {
+			t.Fatal(err)
+		}
+		in := &Data{}
+		if err := h.load("sub/data.json", in); err != nil || in.Name != h.name {
+			t.Fatalf("Load %s failed: %v, %#v", h.name, err, in)
+		}
+	}
+	fi, err := os.Stat(dir.Runtime())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0700 {
+		t.Fatalf("SaveRuntime failed: mode %v", fi.Mode())
+	}
+}
+
+// setenv sets environment variables in env and returns a function that
+// restores their previous values.
+func setenv(env map[string]string) func() {
+	restore := make(map[string]*string)
+	for key, val := range env {
+		if old, ok := os.LookupEnv(key); ok {
+			restore[key] = &old
+		} else {
+			restore[key] = nil
+		}
+		os.Setenv(key, val)
+	}
+	return func() {
+		for key, val := range restore {
+			if val == nil {
+				os.Unsetenv(key)
+			} else {
+				os.Setenv(key, *val)
+			}
+		}
+	}
+}
+
+func TestDirRoots(t *testing.T) {
+	type Config struct {
+		Name string
+		Age  int
+	}
+	root, err := ioutil.TempDir("", "configtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	etc, home, local := filepath.Join(root, "etc"), filepath.Join(root, "home"), filepath.Join(root, "local")
+	dir, err := NewDirRoots("configtest",
+		Root{Path: etc, ReadOnly: true},
+		Root{Path: home},
+		Root{Path: local, ReadOnly: true},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir.User() != home || !reflect.DeepEqual(dir.SystemDirs(), []string{local, etc}) {
+		t.Fatalf("NewDirRoots failed: %s, %v", dir.User(), dir.SystemDirs())
+	}
+	if err := os.MkdirAll(etc, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteConfigFile(filepath.Join(etc, "config.json"), &Config{"etc", 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := dir.SaveConfig("config.json", &Config{Name: "home"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(home, "config.json")); err != nil {
+		t.Fatalf("SaveConfig failed: %v", err)
+	}
+	if err := dir.SaveSystemConfig("config.json", &Config{}); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("SaveSystemConfig failed: expected ErrReadOnly, got %v", err)
+	}
+	in := &Config{}
+	if err := dir.LoadConfig("config.json", true, in); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, &Config{Name: "home"}) {
+		t.Fatalf("LoadConfig failed: %#v", in)
+	}
+	override := filepath.Join(root, "override")
+	dir.AddRoots(Root{Path: override})
+	if err := dir.SaveConfig("config.json", &Config{Age: 2}); err != nil {
+		t.Fatal(err)
+	}
+	in = &Config{}
+	if err := dir.LoadConfig("config.json", false, in); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, &Config{Age: 2}) {
+		t.Fatalf("AddRoots failed: %#v", in)
+	}
+	if _, err := NewDirRoots("configtest"); !errors.Is(err, ErrInvalidParam) {
+		t.Fatalf("NewDirRoots failed: expected ErrInvalidParam, got %v", err)
+	}
+}
+
+func TestDirPortable(t *testing.T) {
+	type Config struct {
+		Name string
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		t.Skip(err)
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		t.Fatal(err)
+	}
+	progdir := filepath.Dir(exe)
+	if GetProgramConfigPath() != progdir {
+		t.Fatalf("GetProgramConfigPath failed: %s", GetProgramConfigPath())
+	}
+	marker := filepath.Join(progdir, PortableMarker)
+	if err := ioutil.WriteFile(marker, nil, 0644); err != nil {
+		t.Skip(err)
+	}
+	defer os.Remove(marker)
+	dir, err := NewDir("configtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !dir.Portable() || dir.User() != progdir || dir.Data() != filepath.Join(progdir, "data") {
+		t.Fatalf("NewDir failed: portable %t, %s, %s", dir.Portable(), dir.User(), dir.Data())
+	}
+	if err := dir.SaveUserConfig("portable.json", &Config{"portable"}); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(filepath.Join(progdir, "portable.json"))
+	in := &Config{}
+	if err := dir.LoadProgramConfig("portable.json", in); err != nil || in.Name != "portable" {
+		t.Fatalf("LoadProgramConfig failed: %v, %#v", err, in)
+	}
+	in = &Config{}
+	if err := dir.LoadConfig("portable.json", false, in); err != nil || in.Name != "portable" {
+		t.Fatalf("LoadConfig failed: %v, %#v", err, in)
+	}
+}
+
+func TestDirProgramRoot(t *testing.T) {
+	dir, err := NewDir("configtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir.Program() == "" || dir.Portable() {
+		t.Skip("program directory not resolved or portable")
+	}
+	sys, err := GetSystemConfigPaths()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Root{{Path: dir.Program(), ReadOnly: true}}
+	for i := len(sys) - 1; i >= 0; i-- {
+		expected = append(expected, Root{Path: filepath.Join(sys[i], "configtest")})
+	}
+	expected = append(expected, Root{Path: dir.User()})
+	if roots := dir.Roots(); !reflect.DeepEqual(roots, expected) {
+		t.Fatalf("NewDir failed: expected roots %v, got %v", expected, roots)
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// Config file discovery by basename.
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/vedranvuk/config/codec"
+)
+
+// ErrAmbiguousConfig is returned when a config basename matches files of
+// multiple codecs in a single location.
+var ErrAmbiguousConfig = ErrConfig.WrapFormat("ambiguous config '%s': %s")
+
+// FindConfig returns paths of config files named basename with an extension
+// of any registered codec in roots of Dir in order of ascending priority, at
+// most one per root. Basename may contain a path which is rooted at each root.
+//
+// If files of multiple codecs match basename in a single root, e.g.
+// "app.json" and "app.yaml", an ErrAmbiguousConfig is returned.
+func (d *Dir) FindConfig(basename string) ([]string, error) {
+	paths := []string{}
+	for _, root := range d.roots {
+		path, err := findConfigFile(filepath.Join(root.Path, basename))
+		if err != nil {
+			return nil, err
+		}
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
+
+// LoadConfigAny is like LoadConfig but loads config files named basename with
+// an extension of any registered codec found by FindConfig, so that a config
+// can be written in any supported format. Files found in different roots may
+// be of different formats.
+//
+// If no config files are found an ErrNoConfigLoaded is returned. If an error
+// occurs it is returned.
+func (d *Dir) LoadConfigAny(basename string, override bool, out interface{}) error {
+	paths, err := d.FindConfig(basename)
+	if err != nil {
+		return err
+	}
+	return loadFiles(paths, override, out, nil)
+}
+
+// findConfigFile returns the path of the file named as basename with an
+// extension of any registered codec or an empty string if none exist.
+func findConfigFile(basename string) (string, error) {
+	var matches []string
+	for _, name := range codec.Names() {
+		path := basename + "." + name
+		if fi, err := os.Stat(path); err == nil && fi.Mode().IsRegular() {
+			matches = append(matches, path)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", nil
+	case 1:
+		return matches[0], nil
+	}
+	return "", ErrAmbiguousConfig.WrapArgs(basename, strings.Join(matches, ", "))
+}
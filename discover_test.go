@@ -0,0 +1,66 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDirLoadConfigAny(t *testing.T) {
+	type Config struct {
+		Name string
+		Age  int
+	}
+	root, err := ioutil.TempDir("", "configtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	sys, usr := filepath.Join(root, "sys"), filepath.Join(root, "usr")
+	dir, err := NewDirRoots("configtest", Root{Path: sys, ReadOnly: true}, Root{Path: usr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		filepath.Join(sys, "app.json"): `{"Name": "sys", "Age": 42}`,
+		filepath.Join(usr, "app.yaml"): "Name: usr\n",
+	}
+	for filename, data := range files {
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	paths, err := dir.FindConfig("app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(paths, []string{filepath.Join(sys, "app.json"), filepath.Join(usr, "app.yaml")}) {
+		t.Fatalf("FindConfig failed: %v", paths)
+	}
+	in := &Config{}
+	if err := dir.LoadConfigAny("app", true, in); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, &Config{"usr", 42}) {
+		t.Fatalf("LoadConfigAny failed: %#v", in)
+	}
+	if err := dir.LoadConfigAny("none", false, in); !errors.Is(err, ErrNoConfigLoaded) {
+		t.Fatalf("LoadConfigAny failed: expected ErrNoConfigLoaded, got %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(usr, "app.toml"), []byte("Name = \"toml\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := dir.LoadConfigAny("app", true, in); !errors.Is(err, ErrAmbiguousConfig) {
+		t.Fatalf("LoadConfigAny failed: expected ErrAmbiguousConfig, got %v", err)
+	}
+}
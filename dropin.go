@@ -0,0 +1,117 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// Drop-in config fragment directories.
+
+package config
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/vedranvuk/config/codec"
+)
+
+// DropInSuffix is the suffix appended to a config name without extension to
+// form the name of its drop-in directory, e.g. "app.d" for "app.json".
+const DropInSuffix = ".d"
+
+// LoadDropIns loads the config specified by name into out using LoadConfig
+// with override, then merges all fragments from drop-in directories of the
+// config in all roots of Dir onto out using Merge and returns paths of
+// fragments that were applied in order of application.
+//
+// A drop-in directory is named as name without extension with DropInSuffix
+// appended, e.g. "app.d" for "app.json". If name has no extension the config
+// is loaded using LoadConfigAny instead.
+//
+// Fragments are files in drop-in directories with an extension of any
+// registered codec and may be of different formats. They are applied in
+// lexical order of their filenames regardless of the root they are in. A
+// fragment in a root of higher priority masks a fragment with the same
+// filename in a root of lower priority. Hidden files, subdirectories and
+// files of unregistered formats are ignored.
+//
+// The main config file is optional if any fragments exist. If neither are
+// found an ErrNoConfigLoaded is returned. If a backup of any file was read
+// all files are loaded and the first ErrBackupRead is returned. If an error
+// occurs it is returned along with paths of fragments applied so far.
+func (d *Dir) LoadDropIns(name string, out interface{}) ([]string, error) {
+	base := strings.TrimSuffix(name, filepath.Ext(name))
+	var err error
+	if base == name {
+		var paths []string
+		if paths, err = d.FindConfig(name); err == nil {
+			err = loadFiles(paths, true, out, nil)
+		}
+	} else {
+		err = d.loadConfig(name, true, out, nil)
+	}
+	var warning error
+	if errors.Is(err, ErrBackupRead) {
+		warning, err = err, nil
+	}
+	if err != nil && !errors.Is(err, ErrNoConfigLoaded) {
+		return nil, err
+	}
+	loaded := err == nil
+	fragments, err := d.dropIns(base + DropInSuffix)
+	if err != nil {
+		return nil, err
+	}
+	applied := make([]string, 0, len(fragments))
+	for _, path := range fragments {
+		if _, err := mergeConfigFile(path, out, false); errors.Is(err, ErrBackupRead) {
+			if warning == nil {
+				warning = err
+			}
+		} else if err != nil {
+			return applied, err
+		}
+		applied = append(applied, path)
+	}
+	if !loaded && len(applied) == 0 {
+		return nil, ErrNoConfigLoaded
+	}
+	return applied, warning
+}
+
+// dropIns returns paths of fragments in drop-in directories specified by
+// name in all roots of Dir in order of application. See LoadDropIns.
+func (d *Dir) dropIns(name string) ([]string, error) {
+	fragments := make(map[string]string)
+	for _, root := range d.roots {
+		dir := filepath.Join(root.Path, name)
+		infos, err := ioutil.ReadDir(dir)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		for _, fi := range infos {
+			if !fi.Mode().IsRegular() || strings.HasPrefix(fi.Name(), ".") {
+				continue
+			}
+			if _, err := codec.Get(ext(fi.Name())); err != nil {
+				continue
+			}
+			fragments[fi.Name()] = filepath.Join(dir, fi.Name())
+		}
+	}
+	names := make([]string, 0, len(fragments))
+	for name := range fragments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	paths := make([]string, 0, len(names))
+	for _, name := range names {
+		paths = append(paths, fragments[name])
+	}
+	return paths, nil
+}
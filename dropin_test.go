@@ -0,0 +1,76 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDirLoadDropIns(t *testing.T) {
+	type Config struct {
+		Name  string
+		Port  int
+		Debug bool
+		Tags  []string `config:"merge=append"`
+	}
+	root, err := ioutil.TempDir("", "configtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	sys, usr := filepath.Join(root, "sys"), filepath.Join(root, "usr")
+	dir, err := NewDirRoots("configtest", Root{Path: sys, ReadOnly: true}, Root{Path: usr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		filepath.Join(sys, "app.json"):            `{"Name": "app", "Port": 80, "Tags": ["main"]}`,
+		filepath.Join(sys, "app.d", "10-a.json"):  `{"Port": 8080, "Tags": ["a"]}`,
+		filepath.Join(sys, "app.d", "30-c.json"):  `{"Name": "masked"}`,
+		filepath.Join(sys, "app.d", "README"):     `not a fragment`,
+		filepath.Join(usr, "app.d", "20-b.yaml"):  "Debug: true\nTags: [b]\n",
+		filepath.Join(usr, "app.d", "30-c.json"):  `{"Tags": ["c"]}`,
+		filepath.Join(usr, "app.d", ".40-d.json"): `{"Name": "hidden"}`,
+	}
+	for filename, data := range files {
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	in := &Config{}
+	applied, err := dir.LoadDropIns("app.json", in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fragments := []string{
+		filepath.Join(sys, "app.d", "10-a.json"),
+		filepath.Join(usr, "app.d", "20-b.yaml"),
+		filepath.Join(usr, "app.d", "30-c.json"),
+	}
+	if !reflect.DeepEqual(applied, fragments) {
+		t.Fatalf("LoadDropIns failed: applied %v", applied)
+	}
+	out := &Config{"app", 8080, true, []string{"main", "a", "b", "c"}}
+	if !reflect.DeepEqual(in, out) {
+		t.Fatalf("LoadDropIns failed: %#v", in)
+	}
+	if err := os.Remove(filepath.Join(sys, "app.json")); err != nil {
+		t.Fatal(err)
+	}
+	in = &Config{}
+	if _, err := dir.LoadDropIns("app", in); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, &Config{"", 8080, true, []string{"a", "b", "c"}}) {
+		t.Fatalf("LoadDropIns failed: %#v", in)
+	}
+}
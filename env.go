@@ -0,0 +1,191 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// Config struct field loading from environment variables.
+
+package config
+
+import (
+	"encoding"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/vedranvuk/strconvex"
+)
+
+var (
+	// ErrInvalidEnv is returned when an environment variable value cannot be
+	// converted to the type of the field it is being loaded into.
+	ErrInvalidEnv = ErrConfig.WrapFormat("invalid value of environment variable '%s'")
+)
+
+const (
+	// EnvKey is a tag that defines the name of the environment variable to
+	// load the field value from. If the field is a struct the name is used as
+	// a prefix for names of its fields. Prefix given to LoadEnv is not applied
+	// to names defined by this key. A value of "-" skips the field.
+	EnvKey = "env"
+)
+
+// LoadEnv takes a pointer to a config struct and recursively traverses
+// possibly nested fields then sets their values to values of environment
+// variables named after the path to the field, if defined.
+//
+// Names of variables are field names at each level of the path to the field
+// uppercased and joined with a "_" and prefixed with prefix. For example, a
+// field accessed as Database.User with prefix "APP" is loaded from variable
+// "APP_DATABASE_USER". Fields of embedded structs are named as if they were
+// fields of the embedding struct and elements of slices of structs are named
+// by their index, e.g. "APP_SERVERS_0_HOST". Name can be overridden using
+// the env key in the config tag.
+//
+// Values are converted using TextUnmarshaler if the field implements it and
+// strconvex otherwise. Slices are loaded from comma separated values. Nil
+// pointers are allocated only if a variable for the field or any of its
+// nested fields is defined. Interface fields are traversed if they contain a
+// pointer, e.g. an initialized Interface.Value.
+//
+// If config is not a pointer to a struct an ErrInvalidParam is returned.
+// If a variable value cannot be converted to field type ErrInvalidEnv is
+// returned and config may be partially loaded.
+func LoadEnv(prefix string, config interface{}) error {
+	v := reflect.Indirect(reflect.ValueOf(config))
+	if !v.IsValid() || v.Kind() != reflect.Struct {
+		return ErrInvalidParam
+	}
+	_, err := loadEnv(v, strings.ToUpper(strings.TrimSuffix(prefix, "_")))
+	return err
+}
+
+// envName returns name joined with prefix.
+func envName(prefix, name string) string {
+	if prefix == "" {
+		return strings.ToUpper(name)
+	}
+	return prefix + "_" + strings.ToUpper(name)
+}
+
+// loadEnv is the implementation of LoadEnv. It returns true if any values
+// were set at any depth in v.
+func loadEnv(v reflect.Value, name string) (bool, error) {
+	switch v.Kind() {
+	case reflect.Struct:
+		if isTextUnmarshaler(v.Type()) {
+			break
+		}
+		loaded := false
+		for i := 0; i < v.NumField(); i++ {
+			sf := v.Type().Field(i)
+			if sf.PkgPath != "" {
+				continue
+			}
+			fname := envName(name, sf.Name)
+			key, tagged := parseTagmap(sf.Tag.Get(ConfigTag))[EnvKey]
+			if key == "-" {
+				continue
+			}
+			if tagged {
+				fname = key
+			} else if sf.Anonymous {
+				fname = name
+			}
+			ok, err := loadEnv(v.Field(i), fname)
+			if err != nil {
+				return loaded, err
+			}
+			loaded = loaded || ok
+		}
+		return loaded, nil
+	case reflect.Array, reflect.Slice:
+		if et := v.Type().Elem(); et.Kind() != reflect.Struct && !(et.Kind() == reflect.Ptr && et.Elem().Kind() == reflect.Struct) {
+			break
+		}
+		loaded := false
+		for i := 0; i < v.Len(); i++ {
+			ok, err := loadEnv(v.Index(i), envName(name, strconv.Itoa(i)))
+			if err != nil {
+				return loaded, err
+			}
+			loaded = loaded || ok
+		}
+		return loaded, nil
+	case reflect.Interface:
+		if v.IsNil() || v.Elem().Kind() != reflect.Ptr {
+			return false, nil
+		}
+		return loadEnv(v.Elem(), name)
+	case reflect.Map, reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		return false, nil
+	case reflect.Ptr:
+		if !v.IsNil() {
+			return loadEnv(v.Elem(), name)
+		}
+		if v.Type().Elem().Kind() != reflect.Struct || isTextUnmarshaler(v.Type().Elem()) {
+			break
+		}
+		if !v.CanSet() {
+			return false, nil
+		}
+		nv := reflect.New(v.Type().Elem())
+		loaded, err := loadEnv(nv.Elem(), name)
+		if loaded {
+			v.Set(nv)
+		}
+		return loaded, err
+	}
+	value, ok := os.LookupEnv(name)
+	if !ok || !v.CanSet() {
+		return false, nil
+	}
+	if err := setString(v, value); err != nil {
+		return false, ErrInvalidEnv.WrapCauseArgs(err, name)
+	}
+	return true, nil
+}
+
+// setString sets v to s converted to the type of v using TextUnmarshaler if
+// v implements it or strconvex otherwise. Slices are set from comma separated
+// values. Nil pointers are allocated.
+func setString(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		v.Set(reflect.New(v.Type().Elem()))
+	}
+	if tu, ok := v.Interface().(encoding.TextUnmarshaler); ok {
+		return unmarshalText(tu, s)
+	}
+	if v.CanAddr() {
+		if tu, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return unmarshalText(tu, s)
+		}
+	}
+	if v.Kind() == reflect.Slice {
+		var vals []string
+		if s != "" {
+			vals = strings.Split(s, ",")
+		}
+		slice := reflect.MakeSlice(v.Type(), len(vals), len(vals))
+		for i := 0; i < len(vals); i++ {
+			if err := setString(slice.Index(i), strings.TrimSpace(vals[i])); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		return nil
+	}
+	if v.Kind() == reflect.Ptr {
+		return setString(v.Elem(), s)
+	}
+	return strconvex.StringToValue(s, v)
+}
+
+// isTextUnmarshaler returns true if t or a pointer to t implements
+// encoding.TextUnmarshaler.
+func isTextUnmarshaler(t reflect.Type) bool {
+	return t.Implements(textUnmarshalerType) || reflect.PtrTo(t).Implements(textUnmarshalerType)
+}
+
+// textUnmarshalerType is the reflect.Type of encoding.TextUnmarshaler.
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
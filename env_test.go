@@ -0,0 +1,102 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLoadEnv(t *testing.T) {
+	type Server struct {
+		Host string
+	}
+	type Embedded struct {
+		Debug bool
+	}
+	type Database struct {
+		User     string
+		Password string `config:"env=TEST_DB_PASS"`
+		Timeout  time.Duration
+	}
+	type Config struct {
+		Embedded
+		Name     string
+		Age      *int
+		Skip     string `config:"env=-"`
+		Tags     []string
+		Database *Database
+		Backup   *Server
+		Servers  []Server
+	}
+	env := map[string]string{
+		"TEST_DEBUG":            "true",
+		"TEST_NAME":             "Foo",
+		"TEST_AGE":              "42",
+		"TEST_SKIP":             "bar",
+		"TEST_TAGS":             "a,b",
+		"TEST_DATABASE_USER":    "admin",
+		"TEST_DB_PASS":          "secret",
+		"TEST_DATABASE_TIMEOUT": "5s",
+		"TEST_SERVERS_1_HOST":   "beta",
+	}
+	for key, val := range env {
+		os.Setenv(key, val)
+		defer os.Unsetenv(key)
+	}
+	in := &Config{Servers: []Server{{"alpha"}, {"gamma"}}}
+	if err := LoadEnv("TEST", in); err != nil {
+		t.Fatal(err)
+	}
+	age := 42
+	out := &Config{
+		Embedded: Embedded{true},
+		Name:     "Foo",
+		Age:      &age,
+		Tags:     []string{"a", "b"},
+		Database: &Database{"admin", "secret", 5 * time.Second},
+		Servers:  []Server{{"alpha"}, {"beta"}},
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Fatalf("LoadEnv failed: %#v", in)
+	}
+	os.Setenv("TEST_AGE", "foo")
+	if err := LoadEnv("TEST", in); !errors.Is(err, ErrInvalidEnv) {
+		t.Fatalf("LoadEnv failed: expected ErrInvalidEnv, got %v", err)
+	}
+}
+
+func TestLoadEnvSlice(t *testing.T) {
+	type Config struct {
+		Ports []int
+		Names []*string
+		Empty []string
+	}
+	env := map[string]string{
+		"TEST_PORTS": "80, 443",
+		"TEST_NAMES": "foo,bar",
+		"TEST_EMPTY": "",
+	}
+	for key, val := range env {
+		os.Setenv(key, val)
+		defer os.Unsetenv(key)
+	}
+	in := &Config{Ports: []int{8080}, Empty: []string{"baz"}}
+	if err := LoadEnv("TEST", in); err != nil {
+		t.Fatal(err)
+	}
+	foo, bar := "foo", "bar"
+	out := &Config{
+		Ports: []int{80, 443},
+		Names: []*string{&foo, &bar},
+		Empty: []string{},
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Fatalf("LoadEnv failed: %#v", in)
+	}
+}
@@ -0,0 +1,243 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// Config struct field binding to command line flags.
+
+package config
+
+import (
+	"flag"
+	"reflect"
+	"strings"
+)
+
+var (
+	// ErrFlagRedefined is returned by BindFlags when a flag with the name
+	// derived from a config field is already defined in the flag set.
+	ErrFlagRedefined = ErrConfig.WrapFormat("flag '%s' redefined")
+	// ErrFlagNotBound is returned by ApplyFlags when a flag for a config field
+	// is not defined in the flag set.
+	ErrFlagNotBound = ErrConfig.WrapFormat("flag '%s' not bound")
+	// ErrInvalidFlag is returned when a flag value cannot be converted to the
+	// type of the field it is being applied to.
+	ErrInvalidFlag = ErrConfig.WrapFormat("invalid value of flag '%s'")
+)
+
+const (
+	// FlagKey is a tag that defines the name of the command line flag bound
+	// to the field. If the field is a struct the name is used as a prefix for
+	// names of its fields. Prefix given to BindFlags is not applied to names
+	// defined by this key. A value of "-" skips the field.
+	FlagKey = "flag"
+	// UsageKey is a tag that defines the usage text of the command line flag
+	// bound to the field.
+	UsageKey = "usage"
+)
+
+// BindFlags takes a pointer to a config struct and registers a flag in fs for
+// each possibly nested leaf field of config, i.e. fields of non-compound types,
+// pointers to such types and fields implementing TextUnmarshaler. Slices of
+// such types are bound as comma separated values. Maps, interfaces and slices
+// of structs are not bound.
+//
+// Names of flags are field names at each level of the path to the field
+// lowercased and joined with a "." and prefixed with prefix if not empty. For
+// example, a field accessed as Database.User with prefix "app" is bound to
+// flag "app.database.user". Fields of embedded structs are named as if they
+// were fields of the embedding struct. Name can be overridden using the flag
+// key in the config tag.
+//
+// Default value shown in flag usage is the value defined by the default key
+// and usage text is the value of usage key followed by the range defined by
+// range key, if any, in the config tag. Flags bound to bool fields can be
+// specified without a value.
+//
+// Flag values are validated when parsed by fs but are not applied to config
+// until ApplyFlags is called after parsing, allowing flags to be applied as
+// the highest priority layer after loading configuration files.
+//
+// If config is not a pointer to a struct an ErrInvalidParam is returned.
+// If a flag is already defined in fs an ErrFlagRedefined is returned.
+func BindFlags(fs *flag.FlagSet, prefix string, config interface{}) error {
+	v := reflect.Indirect(reflect.ValueOf(config))
+	if !v.IsValid() || v.Kind() != reflect.Struct {
+		return ErrInvalidParam
+	}
+	for _, f := range flagFields(v.Type(), strings.ToLower(prefix), nil, nil) {
+		if fs.Lookup(f.name) != nil {
+			return ErrFlagRedefined.WrapArgs(f.name)
+		}
+		usage := f.tags[UsageKey]
+		if rng, ok := f.tags[RangeKey]; ok {
+			usage = strings.TrimSpace(usage + " (range: " + rng + ")")
+		}
+		fs.Var(&flagValue{
+			value:  f.tags[DefaultKey],
+			typ:    f.typ,
+			isBool: indirectType(f.typ).Kind() == reflect.Bool,
+		}, f.name, usage)
+	}
+	return nil
+}
+
+// ApplyFlags takes a pointer to a config struct previously bound to fs using
+// BindFlags and sets fields of config to values of flags that were set when
+// fs was parsed. Flags that were not set are not applied. Prefix must match
+// the prefix given to BindFlags.
+//
+// Values are converted the same way as defaults are set by Default, using
+// TextUnmarshaler if the field implements it and strconvex otherwise. Nil
+// pointers on the path to a field whose flag was set are allocated.
+//
+// If config is not a pointer to a struct an ErrInvalidParam is returned.
+// If a flag for a field is not defined in fs an ErrFlagNotBound is returned.
+// If a flag value cannot be converted to field type an ErrInvalidFlag is
+// returned and config may be partially modified.
+func ApplyFlags(fs *flag.FlagSet, prefix string, config interface{}) error {
+	v := reflect.Indirect(reflect.ValueOf(config))
+	if !v.IsValid() || v.Kind() != reflect.Struct {
+		return ErrInvalidParam
+	}
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	for _, f := range flagFields(v.Type(), strings.ToLower(prefix), nil, nil) {
+		fl := fs.Lookup(f.name)
+		if fl == nil {
+			return ErrFlagNotBound.WrapArgs(f.name)
+		}
+		if !set[f.name] {
+			continue
+		}
+		fld := fieldByIndex(v, f.index)
+		if err := setString(fld, fl.Value.String()); err != nil {
+			return ErrInvalidFlag.WrapCauseArgs(err, f.name)
+		}
+	}
+	return nil
+}
+
+// flagField describes a config field bound to a flag.
+type flagField struct {
+	// name is the flag name.
+	name string
+	// index is the index path to the field from the config struct.
+	index []int
+	// typ is the field type.
+	typ reflect.Type
+	// tags are the field config tags.
+	tags tagmap
+}
+
+// flagFields returns flagFields for leaf fields at any depth in struct type t
+// named with prefix. index is the index path to t from config struct and
+// visiting holds the types of structs on the path used to stop recursion.
+func flagFields(t reflect.Type, prefix string, index []int, visiting []reflect.Type) (result []flagField) {
+	for _, vt := range visiting {
+		if vt == t {
+			return nil
+		}
+	}
+	visiting = append(visiting, t)
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+		tags := parseTagmap(sf.Tag.Get(ConfigTag))
+		name, tagged := tags[FlagKey]
+		if name == "-" {
+			continue
+		}
+		if !tagged {
+			name = strings.ToLower(sf.Name)
+			if prefix != "" {
+				name = prefix + "." + name
+			}
+			if sf.Anonymous {
+				name = prefix
+			}
+		}
+		fi := append(append(make([]int, 0, len(index)+1), index...), i)
+		ft := indirectType(sf.Type)
+		switch {
+		case isTextUnmarshaler(ft):
+		case ft.Kind() == reflect.Struct:
+			result = append(result, flagFields(ft, name, fi, visiting)...)
+			continue
+		case ft.Kind() == reflect.Slice:
+			et := indirectType(ft.Elem())
+			if et.Kind() == reflect.Struct && !isTextUnmarshaler(et) {
+				continue
+			}
+			if et.Kind() == reflect.Slice || et.Kind() == reflect.Map {
+				continue
+			}
+		case ft.Kind() == reflect.Map, ft.Kind() == reflect.Interface,
+			ft.Kind() == reflect.Array, ft.Kind() == reflect.Chan,
+			ft.Kind() == reflect.Func, ft.Kind() == reflect.UnsafePointer:
+			continue
+		}
+		if name == "" {
+			continue
+		}
+		result = append(result, flagField{name, fi, sf.Type, tags})
+	}
+	return
+}
+
+// fieldByIndex returns the field of struct v at index path, allocating nil
+// pointers to structs along the path.
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 {
+			for v.Kind() == reflect.Ptr {
+				if v.IsNil() {
+					v.Set(reflect.New(v.Type().Elem()))
+				}
+				v = v.Elem()
+			}
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// indirectType returns the type t points to at any depth.
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// flagValue is a flag.Value bound to a config field.
+type flagValue struct {
+	// value is the flag value.
+	value string
+	// typ is the type of field the flag is bound to.
+	typ reflect.Type
+	// isBool specifies if the flag is a boolean flag.
+	isBool bool
+}
+
+// String implements flag.Value.String.
+func (fv *flagValue) String() string {
+	if fv == nil {
+		return ""
+	}
+	return fv.value
+}
+
+// Set implements flag.Value.Set. It returns an error if s cannot be
+// converted to the type of field the flag is bound to.
+func (fv *flagValue) Set(s string) error {
+	if err := setString(reflect.New(fv.typ).Elem(), s); err != nil {
+		return err
+	}
+	fv.value = s
+	return nil
+}
+
+// IsBoolFlag implements the optional boolFlag interface of flag package.
+func (fv *flagValue) IsBoolFlag() bool { return fv.isBool }
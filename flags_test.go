@@ -0,0 +1,65 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"errors"
+	"flag"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func TestBindFlags(t *testing.T) {
+	type Database struct {
+		User string `config:"default=admin;usage=database user"`
+		Port int    `config:"range=1:65535"`
+	}
+	type Config struct {
+		Name     string `config:"default=foo"`
+		Verbose  bool
+		Skip     string `config:"flag=-"`
+		Tags     []string
+		Database *Database
+		Backup   *Database `config:"flag=bak"`
+	}
+	in := &Config{Name: "bar"}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	if err := BindFlags(fs, "", in); err != nil {
+		t.Fatal(err)
+	}
+	if f := fs.Lookup("database.user"); f == nil || f.DefValue != "admin" || f.Usage != "database user" {
+		t.Fatalf("BindFlags failed: %#v", f)
+	}
+	if f := fs.Lookup("database.port"); f == nil || f.Usage != "(range: 1:65535)" {
+		t.Fatalf("BindFlags failed: %#v", f)
+	}
+	if fs.Lookup("skip") != nil {
+		t.Fatal("BindFlags failed: skipped field bound")
+	}
+	if err := BindFlags(fs, "", in); !errors.Is(err, ErrFlagRedefined) {
+		t.Fatalf("BindFlags failed: expected ErrFlagRedefined, got %v", err)
+	}
+	if err := fs.Parse([]string{"-verbose", "-tags", "a,b", "-database.port", "8080", "-bak.user", "root"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ApplyFlags(fs, "", in); err != nil {
+		t.Fatal(err)
+	}
+	out := &Config{
+		Name:     "bar",
+		Verbose:  true,
+		Tags:     []string{"a", "b"},
+		Database: &Database{Port: 8080},
+		Backup:   &Database{User: "root"},
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Fatalf("ApplyFlags failed: %#v", in)
+	}
+	if err := fs.Parse([]string{"-database.port", "foo"}); err == nil {
+		t.Fatal("BindFlags failed: invalid value accepted")
+	}
+}
@@ -1,9 +1,12 @@
 module github.com/vedranvuk/config
 
-go 1.14
+go 1.16
 
 require (
+	github.com/BurntSushi/toml v1.2.1
+	github.com/hashicorp/hcl v1.0.0
 	github.com/vedranvuk/errorex v0.3.2
 	github.com/vedranvuk/strconvex v0.0.1
 	github.com/vedranvuk/typeregistry v0.1.0
+	gopkg.in/yaml.v2 v2.4.0
 )
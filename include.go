@@ -0,0 +1,256 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// Config file include directives.
+
+package config
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/vedranvuk/config/codec"
+)
+
+var (
+	// ErrIncludeCycle is returned when config files include each other.
+	ErrIncludeCycle = ErrConfig.WrapFormat("include cycle: %s")
+	// ErrInvalidInclude is returned when an include directive is not a string
+	// or a list of strings or is a malformed pattern.
+	ErrInvalidInclude = ErrConfig.WrapFormat("invalid include '%v' in '%s'")
+	// ErrIncludeNotFound is returned when an included file does not exist.
+	// It does not match os.ErrNotExist so that a missing include is not
+	// mistaken for a missing config file.
+	ErrIncludeNotFound = ErrConfig.WrapFormat("included file '%s' not found in '%s'")
+)
+
+// IncludeKey is the reserved top level key of a config document whose value
+// is a path or a list of paths of config files to include, e.g.:
+//
+//	{
+//		"$include": ["base.json", "conf.d/*.yaml"],
+//		"Name": "app"
+//	}
+//
+// Relative paths are relative to the directory of the including file. Paths
+// may be glob patterns as accepted by filepath.Glob whose matches are
+// included in lexical order. A path that is not a pattern must exist.
+//
+// Included files are read first, in order, and merged into a single document
+// with values of later files overriding those of earlier ones at any depth of
+// nested objects. The including document is merged last so its values
+// override any included values. The merged document is then decoded into the
+// config. Included files may include other files and may be of any format
+// supported by includes. Include cycles are detected and an ErrIncludeCycle
+// is returned.
+//
+// Keys are merged case-insensitively, as codecs decode them, and a key
+// replaces keys that differ from it only in case. Numbers retain their
+// precision if the codec of the file implements codec.RawCodec.
+//
+// Includes are processed by ReadConfigFile and functions that use it and are
+// supported by codecs that can decode into and encode from a
+// map[string]interface{}, e.g. json, yaml and toml.
+const IncludeKey = "$include"
+
+// includeReader returns a reader of the config document read from r and
+// encoded with codec c with includes of the document, which was read from
+// the file specified by filename, expanded. If the document has no includes
+// or codec c does not support them the document is returned as read.
+func includeReader(filename string, r io.Reader, c codec.Codec) (io.Reader, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Contains(data, []byte(IncludeKey)) {
+		return bytes.NewReader(data), nil
+	}
+	path, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := decodeRaw(data, c)
+	if err != nil {
+		return bytes.NewReader(data), nil
+	}
+	if _, ok := raw[IncludeKey]; !ok {
+		return bytes.NewReader(data), nil
+	}
+	if raw, err = includeRaw(path, raw, []string{path}); err != nil {
+		return nil, err
+	}
+	if data, err = c.Encode(raw); err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
+
+// includeRaw returns raw document read from file at absolute path merged
+// onto documents it includes. Stack holds paths of including files.
+func includeRaw(path string, raw map[string]interface{}, stack []string) (map[string]interface{}, error) {
+	include, ok := raw[IncludeKey]
+	if !ok {
+		return raw, nil
+	}
+	delete(raw, IncludeKey)
+	paths, err := includePaths(path, include)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]interface{})
+	for _, match := range paths {
+		included, err := includeFile(match, stack)
+		if err != nil {
+			return nil, err
+		}
+		mergeRaw(result, included)
+	}
+	mergeRaw(result, raw)
+	return result, nil
+}
+
+// includePaths returns paths of files included by include directive value
+// include of a document read from file at absolute path in order of
+// inclusion. Paths that are not patterns are returned whether they exist or
+// not.
+func includePaths(path string, include interface{}) ([]string, error) {
+	var patterns []string
+	switch t := include.(type) {
+	case string:
+		patterns = []string{t}
+	case []interface{}:
+		for _, elem := range t {
+			pattern, ok := elem.(string)
+			if !ok {
+				return nil, ErrInvalidInclude.WrapArgs(elem, path)
+			}
+			patterns = append(patterns, pattern)
+		}
+	default:
+		return nil, ErrInvalidInclude.WrapArgs(include, path)
+	}
+	var paths []string
+	for _, pattern := range patterns {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(path), pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, ErrInvalidInclude.WrapCauseArgs(err, pattern, path)
+		}
+		if len(matches) == 0 && !strings.ContainsAny(pattern, `*?[`) {
+			matches = []string{pattern}
+		}
+		paths = append(paths, matches...)
+	}
+	return paths, nil
+}
+
+// includedFiles returns paths of files included at any depth by the config
+// file specified by filename. Files that cannot be read or decoded and files
+// in seen, which holds absolute paths of visited files, are skipped.
+func includedFiles(filename string, seen map[string]bool) (result []string) {
+	path, err := filepath.Abs(filename)
+	if err != nil || seen[path] {
+		return nil
+	}
+	seen[path] = true
+	c, err := codec.Get(ext(path))
+	if err != nil {
+		return nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil || !bytes.Contains(data, []byte(IncludeKey)) {
+		return nil
+	}
+	raw, err := decodeRaw(data, c)
+	if err != nil {
+		return nil
+	}
+	include, ok := raw[IncludeKey]
+	if !ok {
+		return nil
+	}
+	paths, err := includePaths(path, include)
+	if err != nil {
+		return nil
+	}
+	for _, included := range paths {
+		result = append(result, included)
+		result = append(result, includedFiles(included, seen)...)
+	}
+	return result
+}
+
+// includeFile reads a raw document from an included file specified by
+// filename and expands its includes. Stack holds paths of including files.
+func includeFile(filename string, stack []string) (map[string]interface{}, error) {
+	path, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+	for _, including := range stack {
+		if including == path {
+			return nil, ErrIncludeCycle.WrapArgs(strings.Join(append(stack, path), " -> "))
+		}
+	}
+	c, err := codec.Get(ext(path))
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrIncludeNotFound.WrapArgs(path, stack[len(stack)-1])
+		}
+		return nil, err
+	}
+	raw, err := decodeRaw(data, c)
+	if err != nil {
+		return nil, err
+	}
+	return includeRaw(path, raw, append(stack[:len(stack):len(stack)], path))
+}
+
+// mergeRaw merges raw document src onto raw document dst. Keys are matched
+// case-insensitively and keys of src replace matching keys of dst. Nested
+// objects are merged recursively and other values of src replace values in
+// dst.
+func mergeRaw(dst, src map[string]interface{}) {
+	for key, val := range src {
+		match := key
+		for k := range dst {
+			if strings.EqualFold(k, key) {
+				match = k
+				break
+			}
+		}
+		if sm, ok := val.(map[string]interface{}); ok {
+			if dm, ok := dst[match].(map[string]interface{}); ok {
+				mergeRaw(dm, sm)
+				val = dm
+			}
+		}
+		delete(dst, match)
+		dst[key] = val
+	}
+}
+
+// decodeRaw decodes a config document data encoded with codec c into a raw
+// document using codec.RawCodec if c implements it.
+func decodeRaw(data []byte, c codec.Codec) (map[string]interface{}, error) {
+	if rc, ok := c.(codec.RawCodec); ok {
+		return rc.DecodeRaw(data)
+	}
+	raw := make(map[string]interface{})
+	if err := c.Decode(data, &raw); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
@@ -0,0 +1,104 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadConfigFileInclude(t *testing.T) {
+	type Database struct {
+		Host string
+		Port int
+	}
+	type Config struct {
+		Name     string
+		Debug    bool
+		Database Database
+		Tags     []string
+	}
+	root, err := ioutil.TempDir("", "configtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	files := map[string]string{
+		"app.json":             `{"$include": ["base.yaml", "conf.d/*.json"], "Name": "app"}`,
+		"base.yaml":            "Name: base\nDatabase:\n  Host: localhost\n  Port: 5432\nTags: [base]\n",
+		"conf.d/10-db.json":    `{"Database": {"Port": 6543}}`,
+		"conf.d/20-debug.json": `{"$include": "../debug.toml"}`,
+		"debug.toml":           "Debug = true\nTags = [\"debug\"]\n",
+		"cycle1.json":          `{"$include": "cycle2.json"}`,
+		"cycle2.json":          `{"$include": ["cycle1.json"]}`,
+		"missing.json":         `{"$include": "none.json"}`,
+		"invalid.json":         `{"$include": 42}`,
+	}
+	for filename, data := range files {
+		filename = filepath.Join(root, filename)
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	in := &Config{}
+	if err := ReadConfigFile(filepath.Join(root, "app.json"), in); err != nil {
+		t.Fatal(err)
+	}
+	out := &Config{"app", true, Database{"localhost", 6543}, []string{"debug"}}
+	if !reflect.DeepEqual(in, out) {
+		t.Fatalf("ReadConfigFile failed: %#v", in)
+	}
+	if err := ReadConfigFile(filepath.Join(root, "cycle1.json"), &Config{}); !errors.Is(err, ErrIncludeCycle) {
+		t.Fatalf("ReadConfigFile failed: expected ErrIncludeCycle, got %v", err)
+	}
+	if err := ReadConfigFile(filepath.Join(root, "missing.json"), &Config{}); !errors.Is(err, ErrIncludeNotFound) || errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("ReadConfigFile failed: expected ErrIncludeNotFound, got %v", err)
+	}
+	if err := ReadConfigFile(filepath.Join(root, "invalid.json"), &Config{}); !errors.Is(err, ErrInvalidInclude) {
+		t.Fatalf("ReadConfigFile failed: expected ErrInvalidInclude, got %v", err)
+	}
+}
+
+func TestReadConfigFileIncludeMerge(t *testing.T) {
+	type Database struct {
+		Host string
+		Port int
+	}
+	type Config struct {
+		Name     string
+		ID       int64
+		Database Database
+	}
+	root, err := ioutil.TempDir("", "configtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	files := map[string]string{
+		"app.json":  `{"$include": ["base.json", "db.yaml"], "name": "app"}`,
+		"base.json": `{"Name": "base", "ID": 9007199254740993, "Database": {"Host": "localhost", "Port": 5432}}`,
+		"db.yaml":   "database:\n  port: 6543\n",
+	}
+	for filename, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(root, filename), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	in := &Config{}
+	if err := ReadConfigFile(filepath.Join(root, "app.json"), in); err != nil {
+		t.Fatal(err)
+	}
+	out := &Config{"app", 9007199254740993, Database{"localhost", 6543}}
+	if !reflect.DeepEqual(in, out) {
+		t.Fatalf("ReadConfigFile failed: %#v", in)
+	}
+}
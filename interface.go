@@ -181,7 +181,7 @@ func registerInterfaces(v reflect.Value) error {
 		case reflect.Map:
 			iter := fld.MapRange()
 			for iter.Next() {
-				if err := registerInterface(fld.Index(i)); err != nil {
+				if err := registerInterface(iter.Value()); err != nil {
 					return err
 				}
 			}
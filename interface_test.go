@@ -112,3 +112,28 @@ func TestPInterfaceP(t *testing.T) {
 		t.Fatal("Interface failed.")
 	}
 }
+
+func TestMapInterfaceP(t *testing.T) {
+	type Container struct {
+		M map[string]*Interface
+	}
+	type MapData struct {
+		Name string
+		Age  int
+	}
+	out := &Container{M: map[string]*Interface{"foo": {Value: &MapData{"foo", 42}}}}
+	if err := RegisterInterfaces(out); err != nil {
+		t.Fatal(err)
+	}
+	in := &Container{M: map[string]*Interface{"foo": {Type: out.M["foo"].Type}}}
+	modified, err := InitializeInterfaces(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !modified {
+		t.Fatal("Failed initializing an Interface")
+	}
+	if reflect.TypeOf(out.M["foo"].Value) != reflect.TypeOf(in.M["foo"].Value) {
+		t.Fatal("Interface failed.")
+	}
+}
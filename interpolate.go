@@ -0,0 +1,266 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// Config value interpolation.
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+var (
+	// ErrUnresolved is returned by Interpolate when references cannot be
+	// resolved. It lists all unresolved references with paths of fields
+	// containing them.
+	ErrUnresolved = ErrConfig.WrapFormat("unresolved references: %s")
+	// ErrInterpolationCycle is returned by Interpolate when field references
+	// form a cycle.
+	ErrInterpolationCycle = ErrConfig.WrapFormat("reference cycle: %s")
+)
+
+// Interpolate expands references in string values at any depth in config,
+// which must be a non-nil pointer to a struct, including strings in slices,
+// arrays and maps. A reference is enclosed in "${" and "}" and is one of:
+//
+//	${env:VAR}       value of environment variable VAR
+//	${file:/path}    contents of the file at path without trailing newlines
+//	${.Other.Field}  value of the field at path in config
+//
+// Field paths are formatted the same way as in Provenance, prefixed with a
+// dot, e.g. "${.Servers[0].Host}" or "${.Labels[env]}". Referenced string
+// fields are interpolated before their values are used. Other values are
+// formatted using fmt.Sprint. A literal "${" is written as "$${".
+//
+// If references form a cycle an ErrInterpolationCycle is returned. If any
+// references cannot be resolved an ErrUnresolved listing all of them is
+// returned and values containing them are left unmodified.
+func Interpolate(config interface{}) error {
+	v := reflect.ValueOf(config)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return ErrInvalidParam
+	}
+	in := &interpolator{
+		values: make(map[string]*interpolated),
+		addrs:  make(map[uintptr]*interpolated),
+		seen:   make(map[uintptr]bool),
+	}
+	in.walk("", v.Elem())
+	for _, path := range in.paths {
+		if err := in.resolve(path, nil); err != nil {
+			return err
+		}
+	}
+	for i := len(in.writeback) - 1; i >= 0; i-- {
+		in.writeback[i]()
+	}
+	if len(in.unresolved) > 0 {
+		return ErrUnresolved.WrapArgs(strings.Join(in.unresolved, ", "))
+	}
+	return nil
+}
+
+// ReadConfigFileInterpolated is like ReadConfigFile but interpolates config
+// using Interpolate after reading it.
+//
+// If an error occurs it is returned.
+func ReadConfigFileInterpolated(filename string, config interface{}) error {
+	err := readFile(filename, config, true)
+	if err != nil && !errors.Is(err, ErrBackupRead) {
+		return err
+	}
+	if ierr := Interpolate(config); ierr != nil {
+		return ierr
+	}
+	return err
+}
+
+// LoadConfigInterpolated is like LoadConfig but interpolates out using
+// Interpolate after all config files are loaded so that references resolve
+// to values merged from all locations.
+//
+// If an error occurs it is returned.
+func (d *Dir) LoadConfigInterpolated(name string, override bool, out interface{}) error {
+	err := d.loadConfig(name, override, out, nil)
+	if err != nil && !errors.Is(err, ErrBackupRead) {
+		return err
+	}
+	if ierr := Interpolate(out); ierr != nil {
+		return ierr
+	}
+	return err
+}
+
+// interpolated is a value in a config being interpolated.
+type interpolated struct {
+	value    reflect.Value // value is the value.
+	settable bool          // settable is true for strings that are interpolated.
+	state    int           // state is the resolution state of a string.
+	failed   bool          // failed is true if a string has unresolved references.
+}
+
+// Resolution states of interpolated strings.
+const (
+	stateUnresolved = iota
+	stateResolving
+	stateResolved
+)
+
+// interpolator holds the state of Interpolate.
+type interpolator struct {
+	values     map[string]*interpolated  // values are values by path.
+	addrs      map[uintptr]*interpolated // addrs are interpolated strings by address.
+	paths      []string                  // paths are paths of strings in walk order.
+	seen       map[uintptr]bool          // seen are pointers being walked.
+	writeback  []func()                  // writeback stores copied map elements.
+	unresolved []string                  // unresolved are unresolved references.
+}
+
+// walk records values at any depth in v at path. Elements of maps are walked
+// as copies that are stored back to maps by writeback. Values reachable by
+// multiple paths are recorded at each of them and strings at the same
+// address share their state so that they are interpolated once. Pointers
+// that point back to a value being walked are not followed.
+func (in *interpolator) walk(path string, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return
+		}
+		if v.Kind() == reflect.Ptr {
+			if in.seen[v.Pointer()] {
+				return
+			}
+			in.seen[v.Pointer()] = true
+			defer delete(in.seen, v.Pointer())
+		}
+		in.walk(path, v.Elem())
+		return
+	case reflect.Struct:
+		if isTextUnmarshaler(v.Type()) {
+			break
+		}
+		for i := 0; i < v.NumField(); i++ {
+			if sf := v.Type().Field(i); sf.PkgPath == "" {
+				in.walk(joinPath(path, sf.Name), v.Field(i))
+			}
+		}
+		return
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			in.walk(fmt.Sprintf("%s[%d]", path, i), v.Index(i))
+		}
+		return
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		for _, key := range keys {
+			key, elem := key, reflect.New(v.Type().Elem()).Elem()
+			elem.Set(v.MapIndex(key))
+			in.writeback = append(in.writeback, func() { v.SetMapIndex(key, elem) })
+			in.walk(fmt.Sprintf("%s[%v]", path, key.Interface()), elem)
+		}
+		return
+	case reflect.String:
+		if v.CanSet() {
+			iv, ok := in.addrs[v.UnsafeAddr()]
+			if !ok {
+				iv = &interpolated{value: v, settable: true}
+				in.addrs[v.UnsafeAddr()] = iv
+				in.paths = append(in.paths, path)
+			}
+			in.values[path] = iv
+			return
+		}
+	}
+	in.values[path] = &interpolated{value: v}
+}
+
+// resolve interpolates the string at path. Stack holds paths of strings
+// being resolved that reference it.
+func (in *interpolator) resolve(path string, stack []string) error {
+	iv := in.values[path]
+	switch iv.state {
+	case stateResolved:
+		return nil
+	case stateResolving:
+		return ErrInterpolationCycle.WrapArgs(strings.Join(append(stack, path), " -> "))
+	}
+	iv.state = stateResolving
+	stack = append(stack, path)
+	s, result, ok := iv.value.String(), strings.Builder{}, true
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			result.WriteString(s)
+			break
+		}
+		if i > 0 && s[i-1] == '$' {
+			result.WriteString(s[:i-1] + "${")
+			s = s[i+2:]
+			continue
+		}
+		j := strings.IndexByte(s[i:], '}')
+		if j < 0 {
+			result.WriteString(s)
+			break
+		}
+		result.WriteString(s[:i])
+		ref := s[i+2 : i+j]
+		s = s[i+j+1:]
+		val, found, err := in.reference(ref, stack)
+		if err != nil {
+			return err
+		}
+		if !found {
+			in.unresolved = append(in.unresolved, fmt.Sprintf("${%s} in %s", ref, path))
+			ok = false
+			continue
+		}
+		result.WriteString(val)
+	}
+	iv.state, iv.failed = stateResolved, !ok
+	if ok {
+		iv.value.SetString(result.String())
+	}
+	return nil
+}
+
+// reference returns the value of reference ref and true or false if it does
+// not resolve.
+func (in *interpolator) reference(ref string, stack []string) (string, bool, error) {
+	switch {
+	case strings.HasPrefix(ref, "env:"):
+		val, ok := os.LookupEnv(ref[len("env:"):])
+		return val, ok, nil
+	case strings.HasPrefix(ref, "file:"):
+		data, err := ioutil.ReadFile(ref[len("file:"):])
+		if err != nil {
+			return "", false, nil
+		}
+		return strings.TrimRight(string(data), "\r\n"), true, nil
+	case strings.HasPrefix(ref, "."):
+		path := ref[1:]
+		iv, ok := in.values[path]
+		if !ok {
+			return "", false, nil
+		}
+		if iv.settable {
+			if err := in.resolve(path, stack); err != nil {
+				return "", false, err
+			}
+			return iv.value.String(), !iv.failed, nil
+		}
+		return fmt.Sprint(iv.value.Interface()), true, nil
+	}
+	return "", false, nil
+}
@@ -0,0 +1,155 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInterpolate(t *testing.T) {
+	type Server struct {
+		Host string
+		Port int
+	}
+	type Config struct {
+		Root     string
+		Data     string
+		Logs     []string
+		Password string
+		User     string
+		Primary  Server
+		Backup   *Server
+		Labels   map[string]string
+		Servers  map[string]Server
+		Literal  string
+	}
+	secret, err := ioutil.TempFile("", "configtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(secret.Name())
+	if _, err := secret.WriteString("s3cr3t\n"); err != nil {
+		t.Fatal(err)
+	}
+	secret.Close()
+	defer setenv(map[string]string{"CONFIGTEST_USER": "admin"})()
+	in := &Config{
+		Root:     "/srv/${.Primary.Host}",
+		Data:     "${.Root}/data",
+		Logs:     []string{"${.Data}/log", "${.Labels[env]}.log"},
+		Password: "${file:" + secret.Name() + "}",
+		User:     "${env:CONFIGTEST_USER}",
+		Primary:  Server{"alpha", 80},
+		Backup:   &Server{Host: "${.Primary.Host}:${.Primary.Port}"},
+		Labels:   map[string]string{"env": "prod"},
+		Servers:  map[string]Server{"db": {Host: "db.${.Labels[env]}"}},
+		Literal:  "$${.Root}",
+	}
+	if err := Interpolate(in); err != nil {
+		t.Fatal(err)
+	}
+	out := &Config{
+		Root:     "/srv/alpha",
+		Data:     "/srv/alpha/data",
+		Logs:     []string{"/srv/alpha/data/log", "prod.log"},
+		Password: "s3cr3t",
+		User:     "admin",
+		Primary:  Server{"alpha", 80},
+		Backup:   &Server{Host: "alpha:80"},
+		Labels:   map[string]string{"env": "prod"},
+		Servers:  map[string]Server{"db": {Host: "db.prod"}},
+		Literal:  "${.Root}",
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Fatalf("Interpolate failed: %#v", in)
+	}
+	cycle := &Config{Root: "${.Data}", Data: "${.Root}"}
+	if err := Interpolate(cycle); !errors.Is(err, ErrInterpolationCycle) {
+		t.Fatalf("Interpolate failed: expected ErrInterpolationCycle, got %v", err)
+	}
+	missing := &Config{Root: "${env:CONFIGTEST_NONE}", User: "${.None}"}
+	err = Interpolate(missing)
+	if !errors.Is(err, ErrUnresolved) || !strings.Contains(err.Error(), "${env:CONFIGTEST_NONE} in Root") ||
+		!strings.Contains(err.Error(), "${.None} in User") {
+		t.Fatalf("Interpolate failed: expected ErrUnresolved, got %v", err)
+	}
+}
+
+func TestInterpolateShared(t *testing.T) {
+	type Server struct {
+		Host string
+		URL  string
+	}
+	type Config struct {
+		Primary *Server
+		Current *Server
+		Link    string
+		Literal *string
+		Escaped *string
+	}
+	literal := "$${env:HOME}"
+	primary := &Server{Host: "alpha", URL: "http://${.Current.Host}"}
+	in := &Config{Primary: primary, Current: primary, Link: "${.Current.URL}", Literal: &literal, Escaped: &literal}
+	if err := Interpolate(in); err != nil {
+		t.Fatal(err)
+	}
+	if primary.URL != "http://alpha" || in.Link != "http://alpha" || literal != "${env:HOME}" {
+		t.Fatalf("Interpolate failed: %q, %q, %q", primary.URL, in.Link, literal)
+	}
+}
+
+func TestReadInterpolated(t *testing.T) {
+	type Config struct {
+		Name    string
+		Greet   string
+		Literal string
+	}
+	defer setenv(map[string]string{"CONFIGTEST_USER": "admin"})()
+	dir, err := NewDir("configtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dir.RemoveUser()
+	stored := &Config{"foo", "hello ${.Name}", "$${env:CONFIGTEST_USER}"}
+	if err := dir.SaveUserConfig("interpolation.json", stored); err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(dir.User(), "interpolation.json")
+	in := &Config{}
+	if err := ReadConfigFile(filename, in); err != nil || !reflect.DeepEqual(in, stored) {
+		t.Fatalf("ReadConfigFile failed: %v, %#v", err, in)
+	}
+	in = &Config{}
+	if _, err := dir.LoadConfigProvenance("interpolation.json", false, false, in); err != nil || !reflect.DeepEqual(in, stored) {
+		t.Fatalf("LoadConfigProvenance failed: %v, %#v", err, in)
+	}
+	out := &Config{"foo", "hello foo", "${env:CONFIGTEST_USER}"}
+	in = &Config{}
+	if err := ReadConfigFileInterpolated(filename, in); err != nil || !reflect.DeepEqual(in, out) {
+		t.Fatalf("ReadConfigFileInterpolated failed: %v, %#v", err, in)
+	}
+	for _, override := range []bool{false, true} {
+		in = &Config{}
+		if err := dir.LoadConfigInterpolated("interpolation.json", override, in); err != nil || !reflect.DeepEqual(in, out) {
+			t.Fatalf("LoadConfigInterpolated failed: %v, %#v", err, in)
+		}
+	}
+	if err := dir.UpdateUserConfig("interpolation.json", &Config{}, func(config interface{}) error {
+		config.(*Config).Name = "bar"
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	in = &Config{}
+	if err := dir.LoadConfigInterpolated("interpolation.json", false, in); err != nil || in.Greet != "hello bar" {
+		t.Fatalf("UpdateUserConfig failed: %v, %#v", err, in)
+	}
+}
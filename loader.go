@@ -0,0 +1,465 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// Layered config loading from multiple sources.
+
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/vedranvuk/errorex"
+)
+
+var (
+	// ErrSource is returned by Loader.Load when a source fails to load.
+	ErrSource = ErrConfig.WrapFormat("source '%s'")
+	// ErrInvalidMapKey is returned by a map Source when a key does not
+	// address a field in the config struct.
+	ErrInvalidMapKey = ErrConfig.WrapFormat("invalid map key '%s'")
+	// ErrInvalidMapValue is returned by a map Source when a value cannot be
+	// assigned to the field addressed by its key.
+	ErrInvalidMapValue = ErrConfig.WrapFormat("invalid map value for '%s'")
+)
+
+// Source is a source of configuration values that can be stacked in a Loader.
+type Source interface {
+	// Name must return the name of the source which is recorded in
+	// Provenance as the source of values it sets.
+	Name() string
+	// Load must load values into config which is a pointer to a config
+	// struct or return an error. Fields the source does not define should be
+	// left unmodified.
+	Load(config interface{}) error
+}
+
+// Loader loads a config from multiple sources stacked in a declared order of
+// precedence where sources added later override values set by sources added
+// earlier.
+//
+// A Loader that loads defaults from tags, then system, user and program files
+// then environment variables and finally command line flags would be defined
+// as:
+//
+//	NewLoader(
+//		DefaultsSource(),
+//		dir.SystemSource("app.json"),
+//		dir.UserSource("app.json"),
+//		dir.ProgramSource("app.json"),
+//		EnvSource("APP"),
+//		FlagSource(flag.CommandLine, ""),
+//	)
+type Loader struct {
+	sources []Source
+}
+
+// NewLoader returns a new Loader with optional sources specified in order of
+// ascending precedence.
+func NewLoader(sources ...Source) *Loader {
+	return &Loader{sources: append([]Source{}, sources...)}
+}
+
+// Add adds sources to Loader in order of ascending precedence with a higher
+// precedence than any sources already added and returns the Loader.
+func (l *Loader) Add(sources ...Source) *Loader {
+	l.sources = append(l.sources, sources...)
+	return l
+}
+
+// Sources returns sources of Loader in order of ascending precedence.
+func (l *Loader) Sources() []Source { return append([]Source{}, l.sources...) }
+
+// Load loads config from all sources in order of ascending precedence and
+// returns a Provenance that records the name of the source that last set the
+// value of each field at any depth in config. File sources are recorded as
+// the source of all fields present in their files, other sources as the
+// source of fields whose values they changed.
+//
+// Config must be a pointer to a config struct. It is not reset before
+// loading so any values it holds prior to the call are retained unless
+// overridden by a source and are not recorded in Provenance.
+//
+// Config is not interpolated; Interpolate it after all sources are loaded so
+// that references resolve to values merged from all of them.
+//
+// If config is not a pointer to a struct an ErrInvalidParam is returned.
+// If a backup of any file was read all sources are loaded and the first
+// ErrBackupRead is returned. If a source fails an ErrSource that wraps the
+// cause is returned along with Provenance of sources loaded so far and config
+// may be partially loaded.
+func (l *Loader) Load(config interface{}) (Provenance, error) {
+	v := reflect.ValueOf(config)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return nil, ErrInvalidParam
+	}
+	prov := make(Provenance)
+	var warning error
+	for _, source := range l.sources {
+		var err error
+		if ps, ok := source.(presenceSource); ok {
+			err = prov.loadPresence(source.Name(), config, ps.loadPresence)
+		} else {
+			err = prov.load(source.Name(), config, source.Load)
+		}
+		if errors.Is(err, ErrBackupRead) {
+			if warning == nil {
+				warning = err
+			}
+		} else if err != nil {
+			return prov, ErrSource.WrapCauseArgs(err, source.Name())
+		}
+	}
+	return prov, warning
+}
+
+// sourceFunc is a Source implemented by a function.
+type sourceFunc struct {
+	name string
+	fn   func(config interface{}) error
+}
+
+// Name implements Source.Name.
+func (sf *sourceFunc) Name() string { return sf.name }
+
+// Load implements Source.Load.
+func (sf *sourceFunc) Load(config interface{}) error { return sf.fn(config) }
+
+// presenceSource is a Source that can report which fields were present in
+// the data it loaded so that Loader attributes them to the source even if
+// their values did not change.
+type presenceSource interface {
+	// loadPresence must load config like Source.Load and return the
+	// Presence of loaded data.
+	loadPresence(config interface{}) (Presence, error)
+}
+
+// SourceFunc returns a Source named name that loads config using fn.
+func SourceFunc(name string, fn func(config interface{}) error) Source {
+	return &sourceFunc{name, fn}
+}
+
+// DefaultsSource returns a Source named "defaults" that sets fields to values
+// defined by the default key in config tags using Default without reset.
+// Warnings about fields without tags or default values are ignored.
+func DefaultsSource() Source {
+	return SourceFunc("defaults", func(config interface{}) error {
+		err := Default(config, false)
+		warnings, ok := err.(*errorex.ErrorEx)
+		if !ok || !errors.Is(err, ErrWarning) {
+			return err
+		}
+		for _, extra := range warnings.Extras() {
+			if !errors.Is(extra, ErrNoTag) && !errors.Is(extra, ErrNoDefault) {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// FileSource returns a Source named as filename that reads config from a
+// file using ReadConfigFile into a new value and merges it into config using
+// Merge. A missing file is not an error.
+func FileSource(filename string) Source {
+	return fileSource(filename)
+}
+
+// fileSource is a Source that loads a config file.
+type fileSource string
+
+// Name implements Source.Name.
+func (fs fileSource) Name() string { return string(fs) }
+
+// Load implements Source.Load.
+func (fs fileSource) Load(config interface{}) error {
+	_, err := fs.loadPresence(config)
+	return err
+}
+
+// loadPresence implements presenceSource.loadPresence.
+func (fs fileSource) loadPresence(config interface{}) (Presence, error) {
+	presence, err := mergeConfigFile(string(fs), config, true)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return presence, err
+}
+
+// SystemSource returns a Source that loads the config specified by name from
+// the first system config directory in which it exists. The Source is named
+// as the path of the file in that directory. See FileSource.
+func (d *Dir) SystemSource(name string) Source {
+	return &systemSource{d, name}
+}
+
+// systemSource is a Source that loads a config file from the first system
+// config directory of a Dir in which it exists.
+type systemSource struct {
+	dir  *Dir
+	name string
+}
+
+// Name implements Source.Name.
+func (ss *systemSource) Name() string { return ss.dir.systemPath(ss.name) }
+
+// Load implements Source.Load.
+func (ss *systemSource) Load(config interface{}) error {
+	_, err := ss.loadPresence(config)
+	return err
+}
+
+// loadPresence implements presenceSource.loadPresence.
+func (ss *systemSource) loadPresence(config interface{}) (Presence, error) {
+	if len(ss.dir.sysdirs) == 0 {
+		return nil, nil
+	}
+	return fileSource(ss.Name()).loadPresence(config)
+}
+
+// UserSource returns a Source that loads the config specified by name from
+// the user config directory. See FileSource.
+func (d *Dir) UserSource(name string) Source {
+	return FileSource(filepath.Join(d.usrdir, name))
+}
+
+// ProgramSource returns a Source that loads the config specified by name from
+// the program directory. See FileSource.
+//
+// If the program directory cannot be resolved the Source loads nothing.
+func (d *Dir) ProgramSource(name string) Source {
+	if d.progdir == "" {
+		return SourceFunc(name, func(interface{}) error { return nil })
+	}
+	return FileSource(filepath.Join(d.progdir, name))
+}
+
+// EnvSource returns a Source named "env" that loads config from environment
+// variables using LoadEnv with the specified prefix.
+func EnvSource(prefix string) Source {
+	return SourceFunc("env", func(config interface{}) error {
+		return LoadEnv(prefix, config)
+	})
+}
+
+// FlagSource returns a Source named "flags" that loads config from flags in
+// fs using ApplyFlags with the specified prefix. Config must have been bound
+// to fs using BindFlags and fs parsed prior to loading.
+func FlagSource(fs *flag.FlagSet, prefix string) Source {
+	return SourceFunc("flags", func(config interface{}) error {
+		return ApplyFlags(fs, prefix, config)
+	})
+}
+
+// MapSource returns a Source named name that loads config from values.
+//
+// Keys of values are field names or paths to fields with field names at each
+// level joined with a "." and are matched to field names case insensitively.
+// Values of type map[string]interface{} are loaded into struct fields
+// recursively. Fields promoted from embedded structs are addressed as fields
+// of the embedding struct. Other values are assigned to fields if assignable,
+// converted if both are numeric and strings are converted the same way
+// LoadEnv does.
+//
+// If a key does not address a field an ErrInvalidMapKey is returned.
+// If a value cannot be assigned to a field, including a numeric value that
+// overflows the field type or a float with a fractional part assigned to an
+// integer field, an ErrInvalidMapValue is returned.
+func MapSource(name string, values map[string]interface{}) Source {
+	return SourceFunc(name, func(config interface{}) error {
+		v := reflect.Indirect(reflect.ValueOf(config))
+		if !v.IsValid() || v.Kind() != reflect.Struct {
+			return ErrInvalidParam
+		}
+		return assignMap(v, values, "")
+	})
+}
+
+// assignMap assigns values to fields of struct v. prefix is the path to v and
+// is used for error reporting.
+func assignMap(v reflect.Value, values map[string]interface{}, prefix string) error {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		path := joinPath(prefix, key)
+		fld := v
+		for _, name := range strings.Split(key, ".") {
+			if fld = allocValue(fld); fld.Kind() != reflect.Struct {
+				return ErrInvalidMapKey.WrapArgs(path)
+			}
+			if fld = fieldByName(fld, name); !fld.IsValid() {
+				return ErrInvalidMapKey.WrapArgs(path)
+			}
+		}
+		if err := assignValue(fld, values[key], path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// assignValue assigns val to v. path is the path to v and is used for error
+// reporting.
+func assignValue(v reflect.Value, val interface{}, path string) error {
+	if m, ok := val.(map[string]interface{}); ok {
+		if sv := allocValue(v); sv.Kind() == reflect.Struct {
+			return assignMap(sv, m, path)
+		}
+	}
+	rv := reflect.ValueOf(val)
+	if !rv.IsValid() {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	for {
+		if rv.Type().AssignableTo(v.Type()) {
+			v.Set(rv)
+			return nil
+		}
+		if v.Kind() != reflect.Ptr {
+			break
+		}
+		v = allocValue(v)
+	}
+	if isNumeric(rv.Kind()) && isNumeric(v.Kind()) {
+		if err := setNumber(v, rv); err != nil {
+			return ErrInvalidMapValue.WrapCauseArgs(err, path)
+		}
+		return nil
+	}
+	if rv.Kind() == reflect.String {
+		if err := setString(v, rv.String()); err != nil {
+			return ErrInvalidMapValue.WrapCauseArgs(err, path)
+		}
+		return nil
+	}
+	return ErrInvalidMapValue.WrapArgs(path)
+}
+
+// allocValue dereferences a pointer v once, allocating it if nil. Non
+// pointer values are returned as is.
+func allocValue(v reflect.Value) reflect.Value {
+	if v.Kind() != reflect.Ptr {
+		return v
+	}
+	if v.IsNil() {
+		v.Set(reflect.New(v.Type().Elem()))
+	}
+	return v.Elem()
+}
+
+// fieldByName returns the exported field of struct v whose name matches name
+// case insensitively, including fields promoted from embedded structs, or an
+// invalid value if not found. Nil embedded struct pointers on the path to a
+// promoted field are allocated.
+func fieldByName(v reflect.Value, name string) reflect.Value {
+	sf, ok := v.Type().FieldByNameFunc(func(fname string) bool {
+		return strings.EqualFold(fname, name)
+	})
+	if !ok || sf.PkgPath != "" {
+		return reflect.Value{}
+	}
+	for i, index := range sf.Index {
+		if i > 0 {
+			if v.Kind() == reflect.Ptr && v.IsNil() && !v.CanSet() {
+				return reflect.Value{}
+			}
+			v = allocValue(v)
+		}
+		v = v.Field(index)
+	}
+	return v
+}
+
+// setNumber sets numeric v to numeric n converted to the type of v. If n
+// overflows the type of v or is a float with a fractional part being
+// converted to an integer an error is returned.
+func setNumber(v, n reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		switch n.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			i = n.Int()
+		case reflect.Float32, reflect.Float64:
+			f := n.Float()
+			if f != math.Trunc(f) {
+				return fmt.Errorf("%v is not an integer", f)
+			}
+			if f < math.MinInt64 || f >= 1<<63 {
+				return fmt.Errorf("%v overflows %s", f, v.Type())
+			}
+			i = int64(f)
+		default:
+			if n.Uint() > math.MaxInt64 {
+				return fmt.Errorf("%v overflows %s", n.Uint(), v.Type())
+			}
+			i = int64(n.Uint())
+		}
+		if v.OverflowInt(i) {
+			return fmt.Errorf("%v overflows %s", i, v.Type())
+		}
+		v.SetInt(i)
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		switch n.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			f = float64(n.Int())
+		case reflect.Float32, reflect.Float64:
+			f = n.Float()
+		default:
+			f = float64(n.Uint())
+		}
+		if v.OverflowFloat(f) {
+			return fmt.Errorf("%v overflows %s", f, v.Type())
+		}
+		v.SetFloat(f)
+	default:
+		var u uint64
+		switch n.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if n.Int() < 0 {
+				return fmt.Errorf("%v overflows %s", n.Int(), v.Type())
+			}
+			u = uint64(n.Int())
+		case reflect.Float32, reflect.Float64:
+			f := n.Float()
+			if f != math.Trunc(f) {
+				return fmt.Errorf("%v is not an integer", f)
+			}
+			if f < 0 || f >= 1<<64 {
+				return fmt.Errorf("%v overflows %s", f, v.Type())
+			}
+			u = uint64(f)
+		default:
+			u = n.Uint()
+		}
+		if v.OverflowUint(u) {
+			return fmt.Errorf("%v overflows %s", u, v.Type())
+		}
+		v.SetUint(u)
+	}
+	return nil
+}
+
+// isNumeric returns true if k is a numeric kind.
+func isNumeric(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Uintptr, reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"errors"
+	"flag"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestLoader(t *testing.T) {
+	type Database struct {
+		User string `config:"default=admin"`
+		Port int    `config:"default=5432"`
+	}
+	type Config struct {
+		Name     string `config:"default=foo"`
+		Verbose  bool   `config:"default=false"`
+		Tags     []string
+		Database *Database
+		Labels   map[string]int
+	}
+	const data = `{"Name": "bar", "Database": {"User": "root", "Port": 5432}, "Labels": {"x": 1}}`
+	filename := "testloader.json"
+	if err := ioutil.WriteFile(filename, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(filename)
+	os.Setenv("TESTLOADER_DATABASE_PORT", "6543")
+	defer os.Unsetenv("TESTLOADER_DATABASE_PORT")
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	in := &Config{}
+	if err := BindFlags(fs, "", in); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Parse([]string{"-verbose"}); err != nil {
+		t.Fatal(err)
+	}
+	loader := NewLoader(
+		DefaultsSource(),
+		FileSource(filename),
+		FileSource("nonexistent.json"),
+		EnvSource("TESTLOADER"),
+		FlagSource(fs, ""),
+	).Add(MapSource("map", map[string]interface{}{
+		"tags":     []string{"a", "b"},
+		"Database": map[string]interface{}{"user": "guest"},
+	}))
+	prov, err := loader.Load(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := &Config{
+		Name:     "bar",
+		Verbose:  true,
+		Tags:     []string{"a", "b"},
+		Database: &Database{"guest", 6543},
+		Labels:   map[string]int{"x": 1},
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Fatalf("Loader failed: %#v", in)
+	}
+	sources := map[string]string{
+		"Name":          filename,
+		"Verbose":       "flags",
+		"Tags":          "map",
+		"Database.User": "map",
+		"Database.Port": "env",
+		"Labels":        filename,
+		"Labels[x]":     filename,
+	}
+	if !reflect.DeepEqual(map[string]string(prov), sources) {
+		t.Fatalf("Loader failed: provenance %v", prov)
+	}
+	loader.Add(MapSource("invalid", map[string]interface{}{"Database.Host": "localhost"}))
+	if _, err := loader.Load(in); !errors.Is(err, ErrSource) || !errors.Is(err, ErrInvalidMapKey) {
+		t.Fatalf("Loader failed: expected ErrInvalidMapKey, got %v", err)
+	}
+}
+
+func TestMapSource(t *testing.T) {
+	type Base struct {
+		Name string
+	}
+	type Limits struct {
+		Max uint8
+	}
+	type Config struct {
+		Base
+		*Limits
+		Port  int16
+		Ratio float32
+	}
+	in := &Config{}
+	if err := MapSource("map", map[string]interface{}{
+		"name":  "foo",
+		"Max":   200,
+		"Port":  8080.0,
+		"Ratio": 1,
+	}).Load(in); err != nil {
+		t.Fatal(err)
+	}
+	out := &Config{Base{"foo"}, &Limits{200}, 8080, 1}
+	if !reflect.DeepEqual(in, out) {
+		t.Fatalf("MapSource failed: %#v", in)
+	}
+	for _, values := range []map[string]interface{}{
+		{"Max": 256},
+		{"Max": -1},
+		{"Port": int64(1 << 20)},
+		{"Port": 1.5},
+		{"Ratio": 1e40},
+	} {
+		if err := MapSource("map", values).Load(in); !errors.Is(err, ErrInvalidMapValue) {
+			t.Fatalf("MapSource failed: expected ErrInvalidMapValue for %v, got %v", values, err)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// Advisory config file locking.
+
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// LockSuffix is appended to the name of a config file to name the lock file
+// used to lock it.
+const LockSuffix = ".lock"
+
+// FileLock is an advisory lock on a config file acquired by LockConfigFile.
+type FileLock struct {
+	file *os.File // file is the open lock file.
+}
+
+// LockConfigFile acquires an advisory lock on a config file specified by
+// filename and blocks until it is acquired. If exclusive is specified an
+// exclusive lock for writing is acquired, otherwise a lock for reading that
+// can be shared with other readers.
+//
+// The lock is held on a separate lock file named as filename with LockSuffix
+// appended, created if it does not exist and retained after unlocking, as
+// config files are replaced when written. Locks are advisory and only
+// coordinate processes and goroutines that use them.
+//
+// Locking is supported on Windows and on unix platforms that implement flock.
+// On other platforms the lock file is created but not locked.
+//
+// If an error occurs it is returned.
+func LockConfigFile(filename string, exclusive bool) (*FileLock, error) {
+	file, err := os.OpenFile(filename+LockSuffix, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(file, exclusive); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &FileLock{file}, nil
+}
+
+// Unlock releases the lock.
+func (l *FileLock) Unlock() error {
+	err := unlockFile(l.file)
+	if cerr := l.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// ReadConfigFileLocked is like ReadConfigFile but holds a shared lock on the
+// file while reading. See LockConfigFile.
+//
+// If the lock file cannot be created due to insufficient permissions or a
+// read-only file system, e.g. for a system config file read by a regular
+// user, the file is read without a lock as the caller cannot write it either.
+func ReadConfigFileLocked(filename string, config interface{}) error {
+	lock, err := LockConfigFile(filename, false)
+	if err != nil {
+		if isReadOnly(err) {
+			return ReadConfigFile(filename, config)
+		}
+		return err
+	}
+	defer lock.Unlock()
+	return ReadConfigFile(filename, config)
+}
+
+// WriteConfigFileLocked is like WriteConfigFile but holds an exclusive lock
+// on the file while writing. See LockConfigFile.
+func WriteConfigFileLocked(filename string, config interface{}) error {
+	lock, err := LockConfigFile(filename, true)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+	return WriteConfigFile(filename, config)
+}
+
+// UpdateConfigFile performs a read-modify-write of a config file specified
+// by filename while holding an exclusive lock on it so that concurrent
+// updates do not lose each other's changes. See LockConfigFile.
+//
+// The file is read into config which must be a non-nil pointer to a value
+// compatible with config being read, then fn is called with config to modify
+// it and config is written back to the file. A missing file is not an error
+// and is created with config as modified by fn. Config is not interpolated
+// so that references are written back as read.
+//
+// If fn returns an error the file is not written and the error is returned.
+// If the backup of the file was read the file is updated from it and an
+// ErrBackupRead is returned. If any other error occurs it is returned.
+func UpdateConfigFile(filename string, config interface{}, fn func(config interface{}) error) error {
+	lock, err := LockConfigFile(filename, true)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+	var warning error
+	if err := readFile(filename, config, true); errors.Is(err, ErrBackupRead) {
+		warning = err
+	} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := fn(config); err != nil {
+		return err
+	}
+	if err := WriteConfigFile(filename, config); err != nil {
+		return err
+	}
+	return warning
+}
+
+// UpdateUserConfig performs a read-modify-write of the config specified by
+// name in the user config directory using UpdateConfigFile. If name contains
+// a path subdirectories are created if they don't exist.
+//
+// If an error occurs it is returned.
+func (d *Dir) UpdateUserConfig(name string, out interface{}, fn func(config interface{}) error) error {
+	if err := d.writable(d.usrdir); err != nil {
+		return err
+	}
+	path := filepath.Join(d.usrdir, name)
+	if err := enforceFilePath(path); err != nil {
+		return err
+	}
+	return UpdateConfigFile(path, out, fn)
+}
@@ -0,0 +1,23 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !windows
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!windows
+
+package config
+
+import (
+	"errors"
+	"os"
+)
+
+// lockFile is a no-op on platforms without supported file locking.
+func lockFile(file *os.File, exclusive bool) error { return nil }
+
+// isReadOnly returns true if err is a failure to write due to insufficient
+// permissions.
+func isReadOnly(err error) bool { return errors.Is(err, os.ErrPermission) }
+
+// unlockFile is a no-op on platforms without supported file locking.
+func unlockFile(file *os.File) error { return nil }
@@ -0,0 +1,85 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestDirUpdateUserConfig(t *testing.T) {
+	type Config struct {
+		Counter int
+	}
+	dir, err := NewDir("configtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dir.RemoveUser()
+	const n = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- dir.UpdateUserConfig("counter.json", &Config{}, func(config interface{}) error {
+				config.(*Config).Counter++
+				return nil
+			})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	filename := filepath.Join(dir.User(), "counter.json")
+	out := &Config{}
+	if err := ReadConfigFileLocked(filename, out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Counter != n {
+		t.Fatalf("UpdateUserConfig failed: expected %d, got %d", n, out.Counter)
+	}
+	if _, err := os.Stat(filename + LockSuffix); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadConfigFileLockedReadOnly(t *testing.T) {
+	type Config struct {
+		Name string
+	}
+	dir, err := ioutil.TempDir("", "configtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "readonly.json")
+	if err := WriteConfigFile(filename, &Config{"foo"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(dir, 0555); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(dir, 0755)
+	if file, err := os.OpenFile(filepath.Join(dir, "probe"), os.O_CREATE|os.O_RDWR, 0644); err == nil {
+		file.Close()
+		t.Skip("directory permissions are not enforced")
+	}
+	out := &Config{}
+	if err := ReadConfigFileLocked(filename, out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Name != "foo" {
+		t.Fatalf("ReadConfigFileLocked failed: %#v", out)
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// Config value merging.
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+var (
+	// ErrInvalidMerge is returned when a merge strategy defined by the merge
+	// key is unknown or not applicable to the type of the field.
+	ErrInvalidMerge = ErrConfig.WrapFormat("invalid merge strategy '%s' for '%s'")
+)
+
+const (
+	// MergeKey is a tag that defines the strategy used to merge the field
+	// value when merging configs. Value is one of the Merge* strategies.
+	MergeKey = "merge"
+
+	// MergeReplace replaces the field value with the merged value if the
+	// merged value is not a zero value. It applies to fields of any type and
+	// is the default for fields other than structs and maps.
+	MergeReplace = "replace"
+	// MergeAppend appends elements of the merged slice to the field slice.
+	// It applies to slices only.
+	MergeAppend = "append"
+	// MergeByKey sets elements of the merged map in the field map by key,
+	// replacing existing elements with the same key and retaining others. It
+	// applies to maps only and is the default for maps.
+	MergeByKey = "key"
+	// MergeDeep merges compound values recursively. Fields of structs and
+	// elements of maps, slices and arrays with the same key or index are
+	// merged using their own strategies. It applies to structs, maps, slices
+	// and arrays and is the default for structs.
+	MergeDeep = "deep"
+)
+
+// Merge takes pointers to two values of the same type and merges src into
+// dst using strategies defined by the merge key in config tags of fields at
+// any depth. See Merge* constants for available strategies.
+//
+// Zero values in src are treated as undefined and never override values in
+// dst. Values merged into dst are deep copies and do not share memory with
+// src. Nil pointers in dst are allocated as needed and interfaces are merged
+// recursively if they hold values of the same type in dst and src and are
+// replaced otherwise.
+//
+// If dst and src are not non-nil pointers to the same type an
+// ErrInvalidParam is returned.
+// If a merge strategy is invalid for a field an ErrInvalidMerge is returned
+// and dst may be partially merged.
+func Merge(dst, src interface{}) error {
+	d, s := reflect.ValueOf(dst), reflect.ValueOf(src)
+	if d.Kind() != reflect.Ptr || d.IsNil() || s.Kind() != reflect.Ptr || s.IsNil() || d.Type() != s.Type() {
+		return ErrInvalidParam
+	}
+	return mergeValue(d.Elem(), s.Elem(), "", "", nil)
+}
+
+// MergePresence is like Merge but values in src at paths in presence are
+// merged even if they are zero values, e.g. a field explicitly set to false
+// or a pointer explicitly set to nil in a document src was read from using
+// ReadConfigFilePresence.
+func MergePresence(dst, src interface{}, presence Presence) error {
+	d, s := reflect.ValueOf(dst), reflect.ValueOf(src)
+	if d.Kind() != reflect.Ptr || d.IsNil() || s.Kind() != reflect.Ptr || s.IsNil() || d.Type() != s.Type() {
+		return ErrInvalidParam
+	}
+	return mergeValue(d.Elem(), s.Elem(), "", "", presence)
+}
+
+// mergeValue merges src into dst using strategy. path is the path to dst and
+// is used for error reporting and looking up presence of zero values in
+// presence which may be nil.
+func mergeValue(dst, src reflect.Value, strategy, path string, presence Presence) error {
+	switch strategy {
+	case "", MergeReplace, MergeAppend, MergeByKey, MergeDeep:
+	default:
+		return ErrInvalidMerge.WrapArgs(strategy, path)
+	}
+	switch src.Kind() {
+	case reflect.Ptr:
+		if src.IsNil() {
+			if presence.Has(path) {
+				dst.Set(src)
+			}
+			return nil
+		}
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		return mergeValue(dst.Elem(), src.Elem(), strategy, path, presence)
+	case reflect.Interface:
+		if src.IsNil() {
+			if presence.Has(path) {
+				dst.Set(src)
+			}
+			return nil
+		}
+		if strategy == MergeReplace || dst.IsNil() || dst.Elem().Type() != src.Elem().Type() ||
+			dst.Elem().Kind() != reflect.Ptr || dst.Elem().IsNil() || src.Elem().IsNil() {
+			dst.Set(copyValue(src))
+			return nil
+		}
+		return mergeValue(dst.Elem(), src.Elem(), strategy, path, presence)
+	case reflect.Struct:
+		if strategy == MergeAppend || strategy == MergeByKey {
+			return ErrInvalidMerge.WrapArgs(strategy, path)
+		}
+		if strategy == MergeReplace || src.Type().NumField() == 0 || isTextUnmarshaler(src.Type()) {
+			break
+		}
+		for i := 0; i < src.NumField(); i++ {
+			sf := src.Type().Field(i)
+			if sf.PkgPath != "" {
+				continue
+			}
+			fs := parseTagmap(sf.Tag.Get(ConfigTag))[MergeKey]
+			if err := mergeValue(dst.Field(i), src.Field(i), fs, joinPath(path, sf.Name), presence); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		if strategy == MergeAppend {
+			return ErrInvalidMerge.WrapArgs(strategy, path)
+		}
+		if strategy == MergeReplace {
+			break
+		}
+		if src.Len() == 0 {
+			return nil
+		}
+		if dst.IsNil() {
+			dst.Set(reflect.MakeMapWithSize(dst.Type(), src.Len()))
+		}
+		for iter := src.MapRange(); iter.Next(); {
+			key, val := copyValue(iter.Key()), copyValue(iter.Value())
+			if old := dst.MapIndex(key); strategy == MergeDeep && old.IsValid() {
+				val = copyValue(old)
+				if err := mergeValue(val, iter.Value(), "", fmt.Sprintf("%s[%v]", path, key.Interface()), presence); err != nil {
+					return err
+				}
+			}
+			dst.SetMapIndex(key, val)
+		}
+		return nil
+	case reflect.Slice:
+		if strategy == MergeByKey {
+			return ErrInvalidMerge.WrapArgs(strategy, path)
+		}
+		if src.IsNil() {
+			if presence.Has(path) && strategy != MergeAppend {
+				dst.Set(src)
+			}
+			return nil
+		}
+		switch strategy {
+		case MergeAppend:
+			dst.Set(reflect.AppendSlice(dst, copyValue(src)))
+			return nil
+		case MergeDeep:
+			if dst.Len() < src.Len() {
+				dst.Set(reflect.AppendSlice(dst, reflect.MakeSlice(dst.Type(), src.Len()-dst.Len(), src.Len()-dst.Len())))
+			}
+			return mergeElems(dst, src, path, presence)
+		}
+		dst.Set(copyValue(src))
+		return nil
+	case reflect.Array:
+		if strategy == MergeAppend || strategy == MergeByKey {
+			return ErrInvalidMerge.WrapArgs(strategy, path)
+		}
+		if strategy == MergeDeep {
+			return mergeElems(dst, src, path, presence)
+		}
+	default:
+		if strategy == MergeAppend || strategy == MergeByKey || strategy == MergeDeep {
+			return ErrInvalidMerge.WrapArgs(strategy, path)
+		}
+	}
+	if !src.IsZero() || presence.Has(path) {
+		dst.Set(copyValue(src))
+	}
+	return nil
+}
+
+// mergeElems merges elements of slice or array src into elements of dst with
+// the same index. dst must be at least as long as src.
+func mergeElems(dst, src reflect.Value, path string, presence Presence) error {
+	for i := 0; i < src.Len(); i++ {
+		if err := mergeValue(dst.Index(i), src.Index(i), "", fmt.Sprintf("%s[%d]", path, i), presence); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeConfigFile reads a config file specified by filename into a new value
+// of the type config points to and merges it into config. Zero values
+// present in the file are merged. It returns the Presence of the file. If
+// migrate is not specified the file is not migrated. If the backup of the
+// file was read it is merged and an ErrBackupRead is returned.
+func mergeConfigFile(filename string, config interface{}, migrate bool) (Presence, error) {
+	v := reflect.ValueOf(config)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return nil, ErrInvalidParam
+	}
+	nv := reflect.New(v.Type().Elem())
+	presence, err := readFilePresence(filename, nv.Interface(), migrate)
+	if err != nil && !errors.Is(err, ErrBackupRead) {
+		return nil, err
+	}
+	if merr := MergePresence(config, nv.Interface(), presence); merr != nil {
+		return nil, merr
+	}
+	return presence, err
+}
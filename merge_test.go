@@ -0,0 +1,107 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	type Server struct {
+		Host string
+		Port int
+	}
+	type Config struct {
+		Name     string
+		Debug    bool
+		Tags     []string `config:"merge=append"`
+		Hosts    []string
+		Servers  []Server `config:"merge=deep"`
+		Labels   map[string]string
+		Backends map[string]Server `config:"merge=deep"`
+		Limits   map[string]int    `config:"merge=replace"`
+		Primary  *Server
+		Fallback Server `config:"merge=replace"`
+	}
+	dst := &Config{
+		Name:     "foo",
+		Debug:    true,
+		Tags:     []string{"a"},
+		Hosts:    []string{"alpha"},
+		Servers:  []Server{{"alpha", 80}},
+		Labels:   map[string]string{"x": "1", "y": "2"},
+		Backends: map[string]Server{"db": {"db", 5432}},
+		Limits:   map[string]int{"cpu": 1},
+		Fallback: Server{"alpha", 80},
+	}
+	src := &Config{
+		Tags:     []string{"b"},
+		Hosts:    []string{"beta"},
+		Servers:  []Server{{Port: 8080}, {"beta", 81}},
+		Labels:   map[string]string{"y": "3"},
+		Backends: map[string]Server{"db": {Port: 6543}},
+		Limits:   map[string]int{"mem": 2},
+		Primary:  &Server{Host: "gamma"},
+		Fallback: Server{Host: "beta"},
+	}
+	if err := Merge(dst, src); err != nil {
+		t.Fatal(err)
+	}
+	out := &Config{
+		Name:     "foo",
+		Debug:    true,
+		Tags:     []string{"a", "b"},
+		Hosts:    []string{"beta"},
+		Servers:  []Server{{"alpha", 8080}, {"beta", 81}},
+		Labels:   map[string]string{"x": "1", "y": "3"},
+		Backends: map[string]Server{"db": {"db", 6543}},
+		Limits:   map[string]int{"mem": 2},
+		Primary:  &Server{Host: "gamma"},
+		Fallback: Server{Host: "beta"},
+	}
+	if !reflect.DeepEqual(dst, out) {
+		t.Fatalf("Merge failed: %#v", dst)
+	}
+	if src.Primary == dst.Primary {
+		t.Fatal("Merge failed: pointer shared with src")
+	}
+	type Invalid struct {
+		Name string `config:"merge=append"`
+	}
+	if err := Merge(&Invalid{}, &Invalid{"foo"}); !errors.Is(err, ErrInvalidMerge) {
+		t.Fatalf("Merge failed: expected ErrInvalidMerge, got %v", err)
+	}
+	if err := Merge(&Invalid{}, &Config{}); !errors.Is(err, ErrInvalidParam) {
+		t.Fatalf("Merge failed: expected ErrInvalidParam, got %v", err)
+	}
+}
+
+func TestFileSourceMerge(t *testing.T) {
+	type Config struct {
+		Name string
+		Tags []string `config:"merge=append"`
+	}
+	files := map[string]string{
+		"testmerge1.json": `{"Name": "foo", "Tags": ["a"]}`,
+		"testmerge2.json": `{"Tags": ["b"]}`,
+	}
+	for filename, data := range files {
+		if err := ioutil.WriteFile(filename, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(filename)
+	}
+	in := &Config{}
+	if _, err := NewLoader(FileSource("testmerge1.json"), FileSource("testmerge2.json")).Load(in); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, &Config{"foo", []string{"a", "b"}}) {
+		t.Fatalf("FileSource failed: %#v", in)
+	}
+}
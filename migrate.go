@@ -0,0 +1,249 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// Config schema versioning and migration.
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/vedranvuk/config/codec"
+)
+
+var (
+	// ErrMigration is returned when a migration step fails.
+	ErrMigration = ErrConfig.WrapFormat("migration from version %d failed")
+	// ErrNoMigration is returned when a config document needs to be migrated
+	// from a version for which no migration step is registered.
+	ErrNoMigration = ErrConfig.WrapFormat("no migration from version %d registered")
+	// ErrInvalidVersion is returned when a config document holds a schema
+	// version that is not an integer.
+	ErrInvalidVersion = ErrConfig.WrapFormat("invalid schema version '%v'")
+)
+
+// VersionKey is the name of the key in config documents that holds the
+// schema version of the document. Documents without it are of version 0.
+//
+// Config structs should define an int field named VersionKey without a
+// renaming tag to carry the version. WriteConfig writes it as SchemaVersion
+// if it holds a zero value without modifying the config.
+const VersionKey = "Version"
+
+// MigrateFunc is a function that upgrades a raw config document by one
+// schema version by modifying it in place. Numbers in raw are decoded using
+// codec.RawCodec if the codec implements it, e.g. as json.Number by json.
+type MigrateFunc func(raw map[string]interface{}) error
+
+// Migrate registers fn as the migration step that upgrades raw config
+// documents from version from to version from+1. SchemaVersion is the
+// version following the highest registered step. It panics if a step from
+// the version is already registered.
+//
+// When migrations are registered, main config documents read by
+// ReadConfigFile, ReadConfig and functions that use them, such as
+// Dir.LoadConfig, are decoded into a raw document first and if the document
+// version is lower than SchemaVersion all steps from its version are applied
+// in order before decoding it into the config. The version of the migrated
+// document is set to SchemaVersion. Migrations are applied once per read,
+// after includes are expanded, and not to drop-in fragments, included files
+// on their own or files read by Dir.LoadData, LoadCache, LoadState and
+// LoadRuntime.
+//
+// Migrations apply to codecs that can decode into and encode from a
+// map[string]interface{}, e.g. json, yaml and toml. Documents of other
+// codecs are read as is.
+func Migrate(from int, fn MigrateFunc) {
+	migmu.Lock()
+	defer migmu.Unlock()
+	if _, exists := migrations[from]; exists {
+		panic("config migration registry: migration from version " + strconv.Itoa(from) + " already registered")
+	}
+	migrations[from] = fn
+	if from >= schemaVersion {
+		schemaVersion = from + 1
+	}
+}
+
+// SchemaVersion returns the current config schema version which is the
+// version following the highest version registered with Migrate or 0 if no
+// migrations are registered.
+func SchemaVersion() int {
+	migmu.Lock()
+	defer migmu.Unlock()
+	return schemaVersion
+}
+
+var (
+	// migmu is the migration registry mutex.
+	migmu = sync.Mutex{}
+	// migrations is the migration registry.
+	migrations = map[int]MigrateFunc{}
+	// schemaVersion is the current schema version.
+	schemaVersion = 0
+)
+
+// MigrateConfigFile rewrites a config file specified by filename migrated to
+// SchemaVersion if it is of an older version and returns true if it was
+// rewritten. The previous version of the file is kept as a backup named as
+// filename with BackupSuffix appended. See Migrate.
+//
+// If an error occurs it is returned.
+func MigrateConfigFile(filename string) (bool, error) {
+	c, err := codec.Get(ext(filename))
+	if err != nil {
+		return false, err
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return false, err
+	}
+	migrated, ok, err := migrateData(data, c)
+	if err != nil || !ok {
+		return false, err
+	}
+	target := linkTarget(filename)
+	fi, err := os.Stat(target)
+	if err != nil {
+		return false, err
+	}
+	if err := writeFileAtomic(target+BackupSuffix, fi, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	}); err != nil {
+		return false, err
+	}
+	if err := writeFileAtomic(target, fi, func(w io.Writer) error {
+		_, err := w.Write(migrated)
+		return err
+	}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// MigrateUserConfig rewrites the config specified by name in the user config
+// directory migrated to SchemaVersion using MigrateConfigFile.
+func (d *Dir) MigrateUserConfig(name string) (bool, error) {
+	if err := d.writable(d.usrdir); err != nil {
+		return false, err
+	}
+	return MigrateConfigFile(filepath.Join(d.usrdir, name))
+}
+
+// migrateData migrates config document data encoded with codec c to
+// SchemaVersion. It returns the migrated document and true if it was
+// migrated or data and false if it was not.
+func migrateData(data []byte, c codec.Codec) ([]byte, bool, error) {
+	migmu.Lock()
+	current := schemaVersion
+	migmu.Unlock()
+	if current == 0 {
+		return data, false, nil
+	}
+	raw, err := decodeRaw(data, c)
+	if err != nil {
+		return data, false, nil
+	}
+	key, version := VersionKey, 0
+	for k, v := range raw {
+		if !strings.EqualFold(k, VersionKey) {
+			continue
+		}
+		n, err := strconv.Atoi(fmt.Sprint(jsonNumber(v)))
+		if err != nil {
+			return nil, false, ErrInvalidVersion.WrapArgs(v)
+		}
+		key, version = k, n
+		break
+	}
+	if version >= current {
+		return data, false, nil
+	}
+	for ; version < current; version++ {
+		migmu.Lock()
+		fn, ok := migrations[version]
+		migmu.Unlock()
+		if !ok {
+			return nil, false, ErrNoMigration.WrapArgs(version)
+		}
+		if err := fn(raw); err != nil {
+			return nil, false, ErrMigration.WrapCauseArgs(err, version)
+		}
+	}
+	raw[key] = current
+	migrated, err := c.Encode(raw)
+	if err != nil {
+		return nil, false, err
+	}
+	return migrated, true, nil
+}
+
+// migrateReader returns a reader of the config document read from r and
+// encoded with codec c migrated to SchemaVersion and true if it was migrated.
+// If no migrations are registered r is returned.
+func migrateReader(r io.Reader, c codec.Codec) (io.Reader, bool, error) {
+	if SchemaVersion() == 0 {
+		return r, false, nil
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, false, err
+	}
+	data, ok, err := migrateData(data, c)
+	if err != nil {
+		return nil, false, err
+	}
+	return bytes.NewReader(data), ok, nil
+}
+
+// jsonNumber returns v as a json.Number if it is a float64 so that it is
+// formatted without an exponent.
+func jsonNumber(v interface{}) interface{} {
+	if f, ok := v.(float64); ok {
+		return json.Number(strconv.FormatFloat(f, 'f', -1, 64))
+	}
+	return v
+}
+
+// stampVersion returns config or, if config is a struct or a pointer to a
+// struct whose VersionKey field is an exported int field holding a zero
+// value, a pointer to a copy of the struct with the field set to
+// SchemaVersion. Config is not modified.
+func stampVersion(config interface{}) interface{} {
+	current := SchemaVersion()
+	if current == 0 {
+		return config
+	}
+	v := reflect.Indirect(reflect.ValueOf(config))
+	if v.Kind() != reflect.Struct {
+		return config
+	}
+	sf, ok := v.Type().FieldByName(VersionKey)
+	if !ok || sf.PkgPath != "" {
+		return config
+	}
+	switch sf.Type.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.FieldByIndex(sf.Index).Int() != 0 {
+			return config
+		}
+	default:
+		return config
+	}
+	cp := reflect.New(v.Type())
+	cp.Elem().Set(copyValue(v))
+	cp.Elem().FieldByIndex(sf.Index).SetInt(int64(current))
+	return cp.Interface()
+}
@@ -0,0 +1,169 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMigrate(t *testing.T) {
+	defer func() {
+		migrations = map[int]MigrateFunc{}
+		schemaVersion = 0
+	}()
+	type Server struct {
+		Host string
+	}
+	type Config struct {
+		Version int
+		User    string
+		Server  Server
+	}
+	Migrate(0, func(raw map[string]interface{}) error {
+		raw["User"] = raw["Username"]
+		delete(raw, "Username")
+		return nil
+	})
+	Migrate(1, func(raw map[string]interface{}) error {
+		raw["Server"] = map[string]interface{}{"Host": raw["Host"]}
+		delete(raw, "Host")
+		return nil
+	})
+	if SchemaVersion() != 2 {
+		t.Fatalf("Migrate failed: expected schema version 2, got %d", SchemaVersion())
+	}
+	out := &Config{2, "foo", Server{"localhost"}}
+	for ext, data := range map[string]string{
+		"json": `{"Username": "foo", "Host": "localhost"}`,
+		"yaml": "Version: 1\nUser: foo\nHost: localhost\n",
+	} {
+		filename := "testmigrate." + ext
+		if err := ioutil.WriteFile(filename, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(filename)
+		defer os.Remove(filename + BackupSuffix)
+		in := &Config{}
+		if err := ReadConfigFile(filename, in); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(in, out) {
+			t.Fatalf("ReadConfigFile failed (%s): %#v", ext, in)
+		}
+		if ok, err := MigrateConfigFile(filename); err != nil || !ok {
+			t.Fatalf("MigrateConfigFile failed (%s): %v", ext, err)
+		}
+		if ok, err := MigrateConfigFile(filename); err != nil || ok {
+			t.Fatalf("MigrateConfigFile failed (%s): migrated twice, %v", ext, err)
+		}
+		backup, err := ioutil.ReadFile(filename + BackupSuffix)
+		if err != nil || string(backup) != data {
+			t.Fatalf("MigrateConfigFile failed (%s): backup %q, %v", ext, backup, err)
+		}
+		in = &Config{}
+		if err := ReadConfigFile(filename, in); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(in, out) {
+			t.Fatalf("ReadConfigFile failed (%s): %#v", ext, in)
+		}
+	}
+	filename := "testmigrate.json"
+	if err := WriteConfigFile(filename, &Config{User: "bar"}); err != nil {
+		t.Fatal(err)
+	}
+	in := &Config{}
+	if err := ReadConfigFile(filename, in); err != nil {
+		t.Fatal(err)
+	}
+	if in.Version != 2 || in.User != "bar" {
+		t.Fatalf("WriteConfigFile failed: %#v", in)
+	}
+	Migrate(3, func(raw map[string]interface{}) error { return nil })
+	if err := ReadConfigFile(filename, in); !errors.Is(err, ErrNoMigration) {
+		t.Fatalf("ReadConfigFile failed: expected ErrNoMigration, got %v", err)
+	}
+}
+
+func TestMigrateMainFileOnly(t *testing.T) {
+	defer func() {
+		migrations = map[int]MigrateFunc{}
+		schemaVersion = 0
+	}()
+	type Config struct {
+		Version int
+		User    string
+		Level   string
+	}
+	migrated := 0
+	Migrate(0, func(raw map[string]interface{}) error {
+		migrated++
+		if user, ok := raw["Username"]; ok {
+			raw["User"] = user
+			delete(raw, "Username")
+		}
+		return nil
+	})
+	dir, err := NewDir("configtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dir.RemoveUser()
+	defer os.RemoveAll(dir.Data())
+	if err := os.MkdirAll(filepath.Join(dir.User(), "migrate.d"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string]string{
+		"migrate.json":              `{"$include": "base.json", "Level": "info"}`,
+		"base.json":                 `{"Username": "foo"}`,
+		"migrate.d/10-level.json":   `{"Level": "debug", "Username": "bar"}`,
+		"migrate.d/20-version.json": `{"Version": 0}`,
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir.User(), name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out := &Config{}
+	if _, err := dir.LoadDropIns("migrate.json", out); err != nil {
+		t.Fatal(err)
+	}
+	if migrated != 1 || !reflect.DeepEqual(out, &Config{0, "foo", "debug"}) {
+		t.Fatalf("LoadDropIns failed: %d migrations, %#v", migrated, out)
+	}
+	migrated = 0
+	if err := dir.SaveData("data.json", &Config{User: "baz"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir.Data(), "data.json"), []byte(`{"Username": "baz"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out = &Config{}
+	if err := dir.LoadData("data.json", out); err != nil {
+		t.Fatal(err)
+	}
+	if migrated != 0 || out.User != "" {
+		t.Fatalf("LoadData failed: %d migrations, %#v", migrated, out)
+	}
+	filename := filepath.Join(dir.User(), "migrate.json")
+	if _, err := ReadConfigFilePresence(filename, &Config{}); err != nil || migrated != 1 {
+		t.Fatalf("ReadConfigFilePresence failed: %d migrations, %v", migrated, err)
+	}
+	in := &Config{User: "foo"}
+	if err := WriteConfigFile(filename, in); err != nil {
+		t.Fatal(err)
+	}
+	if in.Version != 0 {
+		t.Fatalf("WriteConfigFile failed: config modified: %#v", in)
+	}
+	out = &Config{}
+	if err := ReadConfigFile(filename, out); err != nil || out.Version != 1 {
+		t.Fatalf("WriteConfigFile failed: version not stamped: %#v, %v", out, err)
+	}
+}
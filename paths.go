@@ -0,0 +1,193 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// Base data, cache, state and runtime directories and XDG Base Directory
+// support.
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+)
+
+// GetSystemConfigPaths returns paths to base system configuration
+// directories in order of descending priority that depend on the running OS
+// and are defined as follows:
+//
+// darwin:             "/private/etc"
+// unix, linux et al:  "$XDG_CONFIG_DIRS" or "/etc/xdg", followed by "/etc"
+// windows:            "%ALLUSERSPROFILE%"
+//
+// If an unsupported OS is detected returns nil paths and ErrUnsupportedOS.
+func GetSystemConfigPaths() (paths []string, err error) {
+	switch runtime.GOOS {
+	case "darwin":
+		paths = []string{"/private/etc"}
+	case "aix", "android", "dragonfly", "freebsd", "illumos", "linux", "netbsd",
+		"openbsd", "plan9", "solaris":
+		paths = appendPath(xdgPaths("XDG_CONFIG_DIRS", "/etc/xdg"), "/etc")
+	case "windows":
+		paths = []string{os.ExpandEnv("$ALLUSERSPROFILE")}
+	default:
+		return nil, ErrUnsupportedOS.WrapArgs(runtime.GOOS)
+	}
+	return
+}
+
+// GetUserDataPath returns the path to the base user data directory that
+// depends on the running OS and is defined as follows:
+//
+// darwin:             "$HOME/Library/Application Support"
+// unix, linux et al:  "$XDG_DATA_HOME" or "$HOME/.local/share"
+// windows:            "%LOCALAPPDATA%"
+//
+// If an unsupported OS is detected returns empty path and ErrUnsupportedOS.
+func GetUserDataPath() (path string, err error) {
+	switch runtime.GOOS {
+	case "darwin":
+		path = filepath.Join(os.ExpandEnv("$HOME"), "Library", "Application Support")
+	case "aix", "android", "dragonfly", "freebsd", "illumos", "linux", "netbsd",
+		"openbsd", "plan9", "solaris":
+		path = xdgPath("XDG_DATA_HOME", filepath.Join(os.ExpandEnv("$HOME"), ".local", "share"))
+	case "windows":
+		path = os.ExpandEnv("$LOCALAPPDATA")
+	default:
+		return "", ErrUnsupportedOS.WrapArgs(runtime.GOOS)
+	}
+	return
+}
+
+// GetSystemDataPaths returns paths to base system data directories in order
+// of descending priority that depend on the running OS and are defined as
+// follows:
+//
+// darwin:             "/Library/Application Support"
+// unix, linux et al:  "$XDG_DATA_DIRS" or "/usr/local/share", "/usr/share"
+// windows:            "%ALLUSERSPROFILE%"
+//
+// If an unsupported OS is detected returns nil paths and ErrUnsupportedOS.
+func GetSystemDataPaths() (paths []string, err error) {
+	switch runtime.GOOS {
+	case "darwin":
+		paths = []string{"/Library/Application Support"}
+	case "aix", "android", "dragonfly", "freebsd", "illumos", "linux", "netbsd",
+		"openbsd", "plan9", "solaris":
+		paths = xdgPaths("XDG_DATA_DIRS", "/usr/local/share", "/usr/share")
+	case "windows":
+		paths = []string{os.ExpandEnv("$ALLUSERSPROFILE")}
+	default:
+		return nil, ErrUnsupportedOS.WrapArgs(runtime.GOOS)
+	}
+	return
+}
+
+// GetUserCachePath returns the path to the base user cache directory that
+// depends on the running OS and is defined as follows:
+//
+// darwin:             "$HOME/Library/Caches"
+// unix, linux et al:  "$XDG_CACHE_HOME" or "$HOME/.cache"
+// windows:            "%LOCALAPPDATA%"
+//
+// If an unsupported OS is detected returns empty path and ErrUnsupportedOS.
+func GetUserCachePath() (path string, err error) {
+	switch runtime.GOOS {
+	case "darwin":
+		path = filepath.Join(os.ExpandEnv("$HOME"), "Library", "Caches")
+	case "aix", "android", "dragonfly", "freebsd", "illumos", "linux", "netbsd",
+		"openbsd", "plan9", "solaris":
+		path = xdgPath("XDG_CACHE_HOME", filepath.Join(os.ExpandEnv("$HOME"), ".cache"))
+	case "windows":
+		path = os.ExpandEnv("$LOCALAPPDATA")
+	default:
+		return "", ErrUnsupportedOS.WrapArgs(runtime.GOOS)
+	}
+	return
+}
+
+// GetUserStatePath returns the path to the base user state directory that
+// depends on the running OS and is defined as follows:
+//
+// darwin:             "$HOME/Library/Application Support"
+// unix, linux et al:  "$XDG_STATE_HOME" or "$HOME/.local/state"
+// windows:            "%LOCALAPPDATA%"
+//
+// If an unsupported OS is detected returns empty path and ErrUnsupportedOS.
+func GetUserStatePath() (path string, err error) {
+	switch runtime.GOOS {
+	case "darwin":
+		path = filepath.Join(os.ExpandEnv("$HOME"), "Library", "Application Support")
+	case "aix", "android", "dragonfly", "freebsd", "illumos", "linux", "netbsd",
+		"openbsd", "plan9", "solaris":
+		path = xdgPath("XDG_STATE_HOME", filepath.Join(os.ExpandEnv("$HOME"), ".local", "state"))
+	case "windows":
+		path = os.ExpandEnv("$LOCALAPPDATA")
+	default:
+		return "", ErrUnsupportedOS.WrapArgs(runtime.GOOS)
+	}
+	return
+}
+
+// GetUserRuntimePath returns the path to the base user runtime directory for
+// sockets, pipes and similar non-essential files that depends on the running
+// OS and is defined as follows:
+//
+// darwin:             "$TMPDIR"
+// unix, linux et al:  "$XDG_RUNTIME_DIR" or "$TMPDIR/runtime-<uid>"
+// windows:            "%TEMP%"
+//
+// If an unsupported OS is detected returns empty path and ErrUnsupportedOS.
+func GetUserRuntimePath() (path string, err error) {
+	switch runtime.GOOS {
+	case "darwin":
+		path = os.TempDir()
+	case "aix", "android", "dragonfly", "freebsd", "illumos", "linux", "netbsd",
+		"openbsd", "plan9", "solaris":
+		path = xdgPath("XDG_RUNTIME_DIR", filepath.Join(os.TempDir(), "runtime-"+strconv.Itoa(os.Getuid())))
+	case "windows":
+		path = os.TempDir()
+	default:
+		return "", ErrUnsupportedOS.WrapArgs(runtime.GOOS)
+	}
+	return
+}
+
+// xdgPath returns the path in environment variable env or def if it is unset
+// or not absolute, as relative paths are invalid per XDG Base Directory
+// Specification.
+func xdgPath(env, def string) string {
+	if path := os.Getenv(env); filepath.IsAbs(path) {
+		return path
+	}
+	return def
+}
+
+// xdgPaths returns absolute paths from a list of paths in environment
+// variable env separated by the OS path list separator or defs if there are
+// none.
+func xdgPaths(env string, defs ...string) (paths []string) {
+	for _, path := range filepath.SplitList(os.Getenv(env)) {
+		if filepath.IsAbs(path) {
+			paths = appendPath(paths, path)
+		}
+	}
+	if len(paths) == 0 {
+		return append([]string{}, defs...)
+	}
+	return
+}
+
+// appendPath appends path to paths if it is not already in paths and returns
+// the result.
+func appendPath(paths []string, path string) []string {
+	path = filepath.Clean(path)
+	for _, p := range paths {
+		if filepath.Clean(p) == path {
+			return paths
+		}
+	}
+	return append(paths, path)
+}
@@ -0,0 +1,262 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// Config field presence tracking.
+
+package config
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"sort"
+
+	"github.com/vedranvuk/config/codec"
+)
+
+// Presence is a set of paths of config fields that were present in a
+// configuration document, including fields explicitly set to zero values.
+//
+// Paths are formatted the same way as in Provenance. A struct field is present
+// if any of its nested fields are present and a pointer field is present if
+// the decoder allocated it. Slices, maps and interfaces are
+// tracked as a whole and paths of their elements are not recorded.
+type Presence map[string]bool
+
+// Paths returns paths of all fields in Presence sorted alphabetically.
+func (p Presence) Paths() []string {
+	paths := make([]string, 0, len(p))
+	for path := range p {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Has returns true if the field at path was present in the document.
+func (p Presence) Has(path string) bool { return p[path] }
+
+// ReadConfigFilePresence is like ReadConfigFile but also returns a Presence
+// that records which fields of config were present in the file.
+//
+// The document is decoded into config once. Interfaces are initialized,
+// migrations are applied and Secrets are resolved once per read.
+//
+// Presence is detected by decoding the file into a zero value and a value
+// prefilled with non-zero values and comparing the results, so it depends on
+// the codec leaving fields not present in the document untouched. Fields of
+// types that cannot be prefilled, such as structs implementing
+// TextUnmarshaler or with unexported fields only, are present if they hold a
+// non-zero value. Codecs that do not encode zero values, such as gob, never
+// report zero values as present.
+//
+// If an error occurs it is returned.
+func ReadConfigFilePresence(filename string, config interface{}) (Presence, error) {
+	return readFilePresence(filename, config, true)
+}
+
+// readFilePresence is the implementation of ReadConfigFilePresence. If
+// migrate is not specified the file is not migrated.
+func readFilePresence(filename string, config interface{}, migrate bool) (presence Presence, err error) {
+	err = readFileWith(filename, config, migrate, func(r io.Reader, c codec.Codec) (err error) {
+		presence, err = readConfigPresence(r, c, config)
+		return
+	})
+	return presence, err
+}
+
+// ReadConfigPresence is like ReadConfig but also returns a Presence that
+// records which fields of config were present in the document read from r.
+// See ReadConfigFilePresence for details.
+//
+// If an error occurs it is returned.
+func ReadConfigPresence(r io.Reader, ext string, config interface{}) (Presence, error) {
+	c, err := codec.Get(ext)
+	if err != nil {
+		return nil, err
+	}
+	if r, _, err = migrateReader(r, c); err != nil {
+		return nil, err
+	}
+	return readConfigPresence(r, c, config)
+}
+
+// readConfigPresence is the implementation of ReadConfigPresence without
+// migration.
+func readConfigPresence(r io.Reader, c codec.Codec, config interface{}) (Presence, error) {
+	v := reflect.ValueOf(config)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return nil, ErrInvalidParam
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := decodeConfig(bytes.NewReader(data), c, config); err != nil {
+		return nil, err
+	}
+	// Copies are decoded as is so that Interfaces are not initialized and
+	// Secrets are not resolved again.
+	zero, filled := reflect.New(v.Type().Elem()), reflect.New(v.Type().Elem())
+	prefill(filled.Elem(), nil)
+	if err := c.Decode(data, zero.Interface()); err != nil {
+		return nil, ErrDecode.WrapCause("", err)
+	}
+	if err := c.Decode(data, filled.Interface()); err != nil {
+		return nil, ErrDecode.WrapCause("", err)
+	}
+	presence := make(Presence)
+	presentValues("", zero.Elem(), filled.Elem(), presence)
+	return presence, nil
+}
+
+// DefaultPresence is like Default but fields at paths in presence are not
+// defaulted even if they hold zero values or reset is specified, so that
+// fields explicitly set to zero values in a document read using
+// ReadConfigFilePresence retain them. Elements of slices and maps are
+// defaulted regardless of presence.
+func DefaultPresence(config interface{}, reset bool, presence Presence) error {
+	v := reflect.Indirect(reflect.ValueOf(config))
+	if !v.IsValid() || v.Kind() != reflect.Struct {
+		return ErrInvalidParam
+	}
+	old := copyValue(v)
+	err := Default(config, reset)
+	restoreValues("", v, old, presence)
+	return err
+}
+
+// restoreValues sets leaf values at any depth in dst whose paths are in
+// presence to values of src at the same paths.
+func restoreValues(path string, dst, src reflect.Value, presence Presence) {
+	switch dst.Kind() {
+	case reflect.Ptr:
+		if dst.IsNil() || src.IsNil() {
+			if presence.Has(path) {
+				dst.Set(src)
+			}
+			return
+		}
+		restoreValues(path, dst.Elem(), src.Elem(), presence)
+		return
+	case reflect.Struct:
+		if !canPrefill(dst.Type()) {
+			break
+		}
+		for i := 0; i < dst.NumField(); i++ {
+			if sf := dst.Type().Field(i); sf.PkgPath == "" {
+				restoreValues(joinPath(path, sf.Name), dst.Field(i), src.Field(i), presence)
+			}
+		}
+		return
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return
+	}
+	if presence.Has(path) && dst.CanSet() {
+		dst.Set(src)
+	}
+}
+
+// prefill sets leaf values at any depth in zero value v to non-zero values.
+// Nil pointers are allocated and their elements are prefilled unless they
+// point to a type in visiting which holds types of structs on the path to v.
+// Slices and maps are set to empty non-nil values.
+func prefill(v reflect.Value, visiting []reflect.Type) {
+	switch v.Kind() {
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(1)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		v.SetUint(1)
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(1)
+	case reflect.Complex64, reflect.Complex128:
+		v.SetComplex(1)
+	case reflect.String:
+		v.SetString("-")
+	case reflect.Slice:
+		v.Set(reflect.MakeSlice(v.Type(), 0, 0))
+	case reflect.Map:
+		v.Set(reflect.MakeMap(v.Type()))
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			prefill(v.Index(i), visiting)
+		}
+	case reflect.Ptr:
+		v.Set(reflect.New(v.Type().Elem()))
+		for _, vt := range visiting {
+			if vt == v.Type().Elem() {
+				return
+			}
+		}
+		prefill(v.Elem(), visiting)
+	case reflect.Struct:
+		if !canPrefill(v.Type()) {
+			return
+		}
+		visiting = append(visiting, v.Type())
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath == "" {
+				prefill(v.Field(i), visiting)
+			}
+		}
+	}
+}
+
+// canPrefill returns true if prefill can set a value of type t to a non-zero
+// value.
+func canPrefill(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Interface, reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		return false
+	case reflect.Array:
+		return t.Len() > 0 && canPrefill(t.Elem())
+	case reflect.Struct:
+		if t == interfaceType || isTextUnmarshaler(t) {
+			return false
+		}
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).PkgPath == "" {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// presentValues records paths of values in Presence that were present in a
+// document decoded into zero and filled values of the same type which were
+// initially zero and prefilled, respectively. It returns true if the value
+// at path was present.
+func presentValues(path string, zero, filled reflect.Value, presence Presence) (present bool) {
+	defer func() {
+		if present && path != "" {
+			presence[path] = true
+		}
+	}()
+	if zero.Kind() == reflect.Ptr {
+		if zero.IsNil() || filled.IsNil() {
+			return zero.IsNil() == filled.IsNil()
+		}
+		present = true
+		zero, filled = zero.Elem(), filled.Elem()
+	}
+	if zero.Kind() == reflect.Struct && canPrefill(zero.Type()) {
+		for i := 0; i < zero.NumField(); i++ {
+			if sf := zero.Type().Field(i); sf.PkgPath == "" {
+				if presentValues(joinPath(path, sf.Name), zero.Field(i), filled.Field(i), presence) {
+					present = true
+				}
+			}
+		}
+		return
+	}
+	if !canPrefill(zero.Type()) {
+		return !zero.IsZero()
+	}
+	return reflect.DeepEqual(zero.Interface(), filled.Interface())
+}
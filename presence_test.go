@@ -0,0 +1,94 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestReadConfigFilePresence(t *testing.T) {
+	type Database struct {
+		User string
+		Port int `config:"default=5432"`
+	}
+	type Config struct {
+		Name     string
+		Debug    bool `config:"default=true"`
+		Age      *int
+		Tags     []string
+		Database *Database
+		Backup   *Database
+	}
+	for _, ext := range []string{"json", "yaml", "toml"} {
+		filename := "testpresence." + ext
+		data := map[string]string{
+			"json": `{"Debug": false, "Tags": [], "Database": {"Port": 0}}`,
+			"yaml": "Debug: false\nTags: []\nDatabase:\n  Port: 0\n",
+			"toml": "Debug = false\nTags = []\n[Database]\nPort = 0\n",
+		}[ext]
+		if err := ioutil.WriteFile(filename, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(filename)
+		in := &Config{}
+		presence, err := ReadConfigFilePresence(filename, in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		paths := []string{"Database", "Database.Port", "Debug", "Tags"}
+		if !reflect.DeepEqual(presence.Paths(), paths) {
+			t.Fatalf("ReadConfigFilePresence failed (%s): %v", ext, presence.Paths())
+		}
+		DefaultPresence(in, false, presence)
+		if in.Debug || in.Database.Port != 0 || in.Backup != nil {
+			t.Fatalf("DefaultPresence failed (%s): %#v", ext, in)
+		}
+		dst := &Config{Name: "foo", Debug: true, Tags: []string{"a"}, Database: &Database{"admin", 5432}}
+		if _, err := mergeConfigFile(filename, dst, true); err != nil {
+			t.Fatal(err)
+		}
+		out := &Config{Name: "foo", Tags: []string{}, Database: &Database{User: "admin"}}
+		if !reflect.DeepEqual(dst, out) {
+			t.Fatalf("MergePresence failed (%s): %#v", ext, dst)
+		}
+	}
+}
+
+func TestReadConfigFilePresenceResolveOnce(t *testing.T) {
+	type Config struct {
+		Key    Secret
+		Keys   map[string]Secret
+		Labels []string
+	}
+	resolved := 0
+	RegisterSecretResolver("count", SecretResolverFunc(func(ref string) (string, error) {
+		resolved++
+		return ref, nil
+	}))
+	defer func() {
+		secretmu.Lock()
+		delete(secretResolvers, "count")
+		secretmu.Unlock()
+	}()
+	filename := "testpresenceonce.json"
+	if err := ioutil.WriteFile(filename, []byte(`{"Key": "count:a", "Keys": {"b": "count:b"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(filename)
+	config := &Config{}
+	presence, err := ReadConfigFilePresence(filename, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved != 2 || config.Key.Value() != "a" || config.Keys["b"].Value() != "b" {
+		t.Fatalf("ReadConfigFilePresence failed: %d resolves, %q, %q", resolved, config.Key.Value(), config.Keys["b"].Value())
+	}
+	if !presence.Has("Key") || !presence.Has("Keys") || presence.Has("Labels") {
+		t.Fatalf("ReadConfigFilePresence failed: %v", presence)
+	}
+}
@@ -0,0 +1,324 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// Config struct field provenance tracking.
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Provenance maps paths of config fields to names of sources that last set
+// their values.
+//
+// Paths are field names at each level of the path to the field joined with a
+// ".", e.g. "Database.User". Slice and array elements are addressed by index
+// and map elements by key, e.g. "Servers[0].Host" and "Labels[tier]".
+type Provenance map[string]string
+
+// Paths returns paths of all fields in Provenance sorted alphabetically.
+func (p Provenance) Paths() []string {
+	paths := make([]string, 0, len(p))
+	for path := range p {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Source returns the name of the source that last set the field at path
+// or an empty string if the field was not set by any source.
+func (p Provenance) Source(path string) string { return p[path] }
+
+// WriteReport writes a report of effective configuration to w that lists
+// the path, value and source of each leaf field at any depth in config, one
+// per line, e.g.:
+//
+//	Database.User = admin (/etc/app/app.json)
+//
+// Fields not set by any source are reported with source "unset". A nil
+// pointer or interface is reported as a single field. Map elements are
+// listed sorted by key.
+//
+// If config is not a pointer to a struct an ErrInvalidParam is returned.
+func (p Provenance) WriteReport(w io.Writer, config interface{}) (err error) {
+	v := reflect.ValueOf(config)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return ErrInvalidParam
+	}
+	walkValues("", v.Elem(), func(path string, v reflect.Value) {
+		if err != nil {
+			return
+		}
+		source, ok := p[path]
+		for parent := path; !ok && parent != ""; {
+			if i := strings.LastIndexAny(parent, ".["); i >= 0 {
+				parent = parent[:i]
+			} else {
+				parent = ""
+			}
+			source, ok = p[parent]
+		}
+		if !ok {
+			source = "unset"
+		}
+		_, err = fmt.Fprintf(w, "%s = %v (%s)\n", path, v.Interface(), source)
+	})
+	return
+}
+
+// load loads config using fn and records name as the source of fields whose
+// values fn changed. If p is nil config is loaded without recording.
+func (p Provenance) load(name string, config interface{}, fn func(config interface{}) error) error {
+	if p == nil {
+		return fn(config)
+	}
+	v := reflect.Indirect(reflect.ValueOf(config))
+	old := copyValue(v)
+	if err := fn(config); err != nil {
+		return err
+	}
+	p.record(name, old, v)
+	return nil
+}
+
+// loadPresence is like load but fn also returns a Presence of fields it
+// loaded and name is also recorded as the source of all fields present,
+// including those whose values did not change. Fields are recorded if fn
+// returns an ErrBackupRead, which is returned.
+func (p Provenance) loadPresence(name string, config interface{}, fn func(config interface{}) (Presence, error)) error {
+	if p == nil {
+		_, err := fn(config)
+		return err
+	}
+	v := reflect.Indirect(reflect.ValueOf(config))
+	old := copyValue(v)
+	presence, err := fn(config)
+	if err != nil && !errors.Is(err, ErrBackupRead) {
+		return err
+	}
+	p.recordPresence(name, presence)
+	p.record(name, old, v)
+	return err
+}
+
+// recordPresence records source as the source of leaf fields in presence,
+// i.e. fields that have no nested fields in presence. Elements of slices and
+// maps present as a whole are recorded individually only if they changed.
+func (p Provenance) recordPresence(source string, presence Presence) {
+	for path := range presence {
+		leaf := true
+		for other := range presence {
+			if strings.HasPrefix(other, path+".") {
+				leaf = false
+				break
+			}
+		}
+		if leaf {
+			p[path] = source
+		}
+	}
+}
+
+// record records source as the source of fields whose values differ between
+// old and new at any depth. Records of fields nested in a recorded field are
+// removed.
+func (p Provenance) record(source string, old, new reflect.Value) {
+	diffValues("", old, new, func(path string) {
+		for key := range p {
+			if strings.HasPrefix(key, path+".") || strings.HasPrefix(key, path+"[") {
+				delete(p, key)
+			}
+		}
+		p[path] = source
+	})
+}
+
+// copyValue returns a deep copy of v. Unexported struct fields are copied
+// shallowly.
+func copyValue(v reflect.Value) reflect.Value {
+	if !v.IsValid() {
+		return v
+	}
+	c := reflect.New(v.Type()).Elem()
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return c
+		}
+		p := reflect.New(v.Type().Elem())
+		p.Elem().Set(copyValue(v.Elem()))
+		c.Set(p)
+	case reflect.Interface:
+		if v.IsNil() {
+			return c
+		}
+		c.Set(copyValue(v.Elem()))
+	case reflect.Struct:
+		c.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if c.Field(i).CanSet() {
+				c.Field(i).Set(copyValue(v.Field(i)))
+			}
+		}
+	case reflect.Slice:
+		if v.IsNil() {
+			return c
+		}
+		c.Set(reflect.MakeSlice(v.Type(), v.Len(), v.Len()))
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(copyValue(v.Index(i)))
+		}
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(copyValue(v.Index(i)))
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			return c
+		}
+		c.Set(reflect.MakeMapWithSize(v.Type(), v.Len()))
+		for iter := v.MapRange(); iter.Next(); {
+			c.SetMapIndex(copyValue(iter.Key()), copyValue(iter.Value()))
+		}
+	default:
+		c.Set(v)
+	}
+	return c
+}
+
+// diffValues calls fn with the path of each leaf value that differs between
+// possibly compound values a and b of the same type. Struct fields, elements
+// of slices and arrays of structs and map elements are compared individually,
+// other values are compared as a whole.
+func diffValues(path string, a, b reflect.Value, fn func(path string)) {
+	// Treat nil pointers and interfaces as zero values of their elements.
+	for a.Kind() == reflect.Ptr || a.Kind() == reflect.Interface {
+		if a.Kind() == reflect.Interface && (a.IsNil() || b.IsNil() || a.Elem().Type() != b.Elem().Type()) {
+			if a.IsNil() != b.IsNil() || (!a.IsNil() && !reflect.DeepEqual(a.Interface(), b.Interface())) {
+				fn(path)
+			}
+			return
+		}
+		if a.Kind() == reflect.Ptr && (a.IsNil() || b.IsNil()) {
+			if a.IsNil() && b.IsNil() {
+				return
+			}
+			if a.IsNil() {
+				a = reflect.New(a.Type().Elem())
+			}
+			if b.IsNil() {
+				b = reflect.New(b.Type().Elem())
+			}
+			if a.Elem().Kind() != reflect.Struct {
+				fn(path)
+				return
+			}
+		}
+		a, b = a.Elem(), b.Elem()
+	}
+	switch a.Kind() {
+	case reflect.Struct:
+		if a.Type().NumField() == 0 || isTextUnmarshaler(a.Type()) {
+			break
+		}
+		for i := 0; i < a.NumField(); i++ {
+			if sf := a.Type().Field(i); sf.PkgPath == "" {
+				diffValues(joinPath(path, sf.Name), a.Field(i), b.Field(i), fn)
+			}
+		}
+		return
+	case reflect.Slice, reflect.Array:
+		if et := indirectType(a.Type().Elem()); et.Kind() != reflect.Struct || isTextUnmarshaler(et) {
+			break
+		}
+		if a.Len() != b.Len() {
+			fn(path)
+			return
+		}
+		for i := 0; i < a.Len(); i++ {
+			diffValues(fmt.Sprintf("%s[%d]", path, i), a.Index(i), b.Index(i), fn)
+		}
+		return
+	case reflect.Map:
+		for iter := a.MapRange(); iter.Next(); {
+			key := fmt.Sprintf("%s[%v]", path, iter.Key().Interface())
+			bval := b.MapIndex(iter.Key())
+			if !bval.IsValid() {
+				fn(key)
+				continue
+			}
+			diffValues(key, iter.Value(), bval, fn)
+		}
+		for iter := b.MapRange(); iter.Next(); {
+			if !a.MapIndex(iter.Key()).IsValid() {
+				fn(fmt.Sprintf("%s[%v]", path, iter.Key().Interface()))
+			}
+		}
+		return
+	}
+	if !reflect.DeepEqual(a.Interface(), b.Interface()) {
+		fn(path)
+	}
+}
+
+// walkValues calls fn with the path and value of each leaf value in possibly
+// compound value v. Leaves are determined the same way as in diffValues.
+func walkValues(path string, v reflect.Value, fn func(path string, v reflect.Value)) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			fn(path, v)
+			return
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		if v.Type().NumField() == 0 || isTextUnmarshaler(v.Type()) {
+			break
+		}
+		for i := 0; i < v.NumField(); i++ {
+			if sf := v.Type().Field(i); sf.PkgPath == "" {
+				walkValues(joinPath(path, sf.Name), v.Field(i), fn)
+			}
+		}
+		return
+	case reflect.Slice, reflect.Array:
+		if et := indirectType(v.Type().Elem()); et.Kind() != reflect.Struct || isTextUnmarshaler(et) {
+			break
+		}
+		for i := 0; i < v.Len(); i++ {
+			walkValues(fmt.Sprintf("%s[%d]", path, i), v.Index(i), fn)
+		}
+		return
+	case reflect.Map:
+		keys := make([]string, 0, v.Len())
+		vals := make(map[string]reflect.Value, v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			key := fmt.Sprintf("%v", iter.Key().Interface())
+			keys = append(keys, key)
+			vals[key] = iter.Value()
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			walkValues(fmt.Sprintf("%s[%s]", path, key), vals[key], fn)
+		}
+		return
+	}
+	fn(path, v)
+}
+
+// joinPath joins a field name to a field path.
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
@@ -0,0 +1,125 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// Configurable configuration search roots of Dir.
+
+package config
+
+import (
+	"path/filepath"
+)
+
+var (
+	// ErrReadOnly is returned when saving a config to a read-only root.
+	ErrReadOnly = ErrConfig.WrapFormat("config root '%s' is read-only")
+	// ErrNoRoot is returned when a Dir has no root for the config location
+	// being accessed.
+	ErrNoRoot = ErrConfig.Wrap("no config root for location")
+)
+
+// Root is a configuration directory searched by a Dir.
+type Root struct {
+	// Path is the path of the directory. It is used as is and is not joined
+	// with Dir prefix.
+	Path string
+	// ReadOnly specifies that configs must not be saved to Path.
+	ReadOnly bool
+}
+
+// NewDirRoots returns a new Dir with the given prefix that searches for
+// configs in specified roots instead of platform configuration locations.
+// Roots are specified in order of ascending priority, e.g.:
+//
+//	NewDirRoots("app",
+//		Root{Path: "/etc/app", ReadOnly: true},
+//		Root{Path: "/opt/app/etc", ReadOnly: true},
+//		Root{Path: filepath.Join(home, ".config/app")},
+//		Root{Path: "./config"},
+//		Root{Path: *configDir},
+//	)
+//
+// LoadConfig and Watch search all roots. SaveConfig saves to the writable
+// root with the highest priority. The writable root with the highest priority,
+// or the root with the highest priority if none are writable, is the user
+// location of Dir and the remaining roots in order of descending priority
+// are its system locations. Program directory is not searched. Prefix applies
+// to data, cache, state and runtime locations only. Roots are not created
+// until a config is saved to them.
+//
+// Roots with an empty path are ignored. If no roots are specified an
+// ErrInvalidParam is returned.
+func NewDirRoots(prefix string, roots ...Root) (*Dir, error) {
+	p, err := newDir(prefix)
+	if err != nil {
+		return nil, err
+	}
+	p.AddRoots(roots...)
+	if len(p.roots) == 0 {
+		return nil, ErrInvalidParam
+	}
+	user := len(p.roots) - 1
+	for i := user; i >= 0; i-- {
+		if !p.roots[i].ReadOnly {
+			user = i
+			break
+		}
+	}
+	p.usrdir = p.roots[user].Path
+	for i := len(p.roots) - 1; i >= 0; i-- {
+		if i != user {
+			p.sysdirs = append(p.sysdirs, p.roots[i].Path)
+		}
+	}
+	if len(p.sysdirs) > 0 {
+		p.sysdir = p.sysdirs[0]
+	}
+	return p, nil
+}
+
+// AddRoots adds roots to Dir in order of ascending priority with a higher
+// priority than any roots of Dir, e.g. a directory specified on command line.
+// Roots with an empty path are ignored. Added roots are searched by
+// LoadConfig and are considered by SaveConfig but do not change the user and
+// system locations of Dir.
+//
+// AddRoots must not be called concurrently with other methods of Dir.
+func (d *Dir) AddRoots(roots ...Root) *Dir {
+	for _, root := range roots {
+		if root.Path == "" {
+			continue
+		}
+		root.Path = filepath.Clean(root.Path)
+		d.roots = append(d.roots, root)
+	}
+	return d
+}
+
+// Roots returns configuration search roots of Dir in order of ascending
+// priority.
+func (d *Dir) Roots() []Root { return append([]Root{}, d.roots...) }
+
+// SaveConfig saves a configuration file defined by name to the writable root
+// with the highest priority. If name contains a path subdirectories are
+// created if they don't exist.
+//
+// If Dir has no writable roots an ErrNoRoot is returned.
+// If an error occurs it is returned.
+func (d *Dir) SaveConfig(name string, in interface{}) error {
+	for i := len(d.roots) - 1; i >= 0; i-- {
+		if !d.roots[i].ReadOnly {
+			return saveFile(d.roots[i].Path, name, in, 0755)
+		}
+	}
+	return ErrNoRoot
+}
+
+// writable returns ErrReadOnly if dir is a read-only root of Dir.
+func (d *Dir) writable(dir string) error {
+	for _, root := range d.roots {
+		if root.ReadOnly && root.Path == filepath.Clean(dir) {
+			return ErrReadOnly.WrapArgs(dir)
+		}
+	}
+	return nil
+}
@@ -225,7 +225,7 @@ func setDefaults(v reflect.Value, name string, tags tagmap, reset bool, warnings
 		return
 	}
 	if tu, ok := v.Interface().(encoding.TextUnmarshaler); ok {
-		if err := tu.UnmarshalText([]byte(defval)); err != nil {
+		if err := unmarshalText(tu, defval); err != nil {
 			warnings.Extra(ErrInvalidDefault.WrapCauseArgs(err, name))
 		}
 		return
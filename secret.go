@@ -0,0 +1,279 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// Secret config values.
+
+package config
+
+import (
+	"encoding"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrSecret is returned when a Secret reference cannot be resolved.
+	ErrSecret = ErrConfig.WrapFormat("cannot resolve secret '%s'")
+	// ErrNoSecretResolver is returned when no SecretResolver is registered
+	// for the scheme of a Secret reference.
+	ErrNoSecretResolver = ErrConfig.WrapFormat("no secret resolver registered for '%s'")
+)
+
+// Redacted is the text a Secret prints as.
+const Redacted = "***"
+
+// SecretResolver resolves references of Secrets to secret values.
+type SecretResolver interface {
+	// Resolve must return the secret value referenced by ref, which is the
+	// reference without the scheme, or an error.
+	Resolve(ref string) (string, error)
+}
+
+// SecretResolverFunc is a function that implements SecretResolver.
+type SecretResolverFunc func(ref string) (string, error)
+
+// Resolve implements SecretResolver.Resolve.
+func (f SecretResolverFunc) Resolve(ref string) (string, error) { return f(ref) }
+
+// RegisterSecretResolver registers a SecretResolver for references with the
+// specified scheme, e.g. "vault" for "vault:secret/db". It panics if the
+// scheme is already registered.
+//
+// Resolvers for "env" and "file" schemes are registered by default. "env"
+// resolves to the value of an environment variable which must be set and
+// "file" resolves to the contents of a file without trailing newlines.
+func RegisterSecretResolver(scheme string, resolver SecretResolver) {
+	secretmu.Lock()
+	defer secretmu.Unlock()
+	if _, exists := secretResolvers[scheme]; exists {
+		panic("config secret registry: resolver " + scheme + " already registered")
+	}
+	secretResolvers[scheme] = resolver
+}
+
+var (
+	// secretmu is the secret resolver registry mutex.
+	secretmu = sync.Mutex{}
+	// secretResolvers is the secret resolver registry.
+	secretResolvers = map[string]SecretResolver{
+		"env": SecretResolverFunc(func(ref string) (string, error) {
+			val, ok := os.LookupEnv(ref)
+			if !ok {
+				return "", fmt.Errorf("environment variable '%s' not set", ref)
+			}
+			return val, nil
+		}),
+		"file": SecretResolverFunc(func(ref string) (string, error) {
+			data, err := ioutil.ReadFile(ref)
+			if err != nil {
+				return "", err
+			}
+			return strings.TrimRight(string(data), "\r\n"), nil
+		}),
+	}
+)
+
+// Secret is a config field type that holds a reference to a secret value,
+// such as "file:/run/secrets/db" or "env:DB_PASS", in a config file instead
+// of the value itself.
+//
+// A reference consists of a scheme and a scheme specific reference separated
+// by a colon. When a Secret is read from a config file the reference is
+// resolved using the SecretResolver registered for its scheme, see
+// RegisterSecretResolver, and a failure to resolve it fails reading. An empty
+// reference decodes to an empty Secret. References are resolved once after
+// the config is decoded, by ReadConfigFile, ReadConfig and functions that use
+// them, and when a Secret is set by LoadEnv, ApplyFlags, Default or a
+// MapSource. A Secret decoded directly by a codec holds the reference only.
+//
+// A Secret encodes as its reference so the secret value is never written by
+// WriteConfigFile, and prints as Redacted with fmt.
+//
+// Secret is supported by codecs that support encoding.TextMarshaler and
+// encoding.TextUnmarshaler and by gob, which encodes its reference only.
+type Secret struct {
+	ref      string
+	value    string
+	resolved bool
+}
+
+// NewSecret returns a new Secret with the specified reference resolved or an
+// error.
+func NewSecret(ref string) (Secret, error) {
+	s := Secret{}
+	err := unmarshalText(&s, ref)
+	return s, err
+}
+
+// Ref returns the reference of Secret.
+func (s Secret) Ref() string { return s.ref }
+
+// Value returns the resolved secret value of Secret.
+func (s Secret) Value() string { return s.value }
+
+// String implements fmt.Stringer and returns Redacted.
+func (s Secret) String() string { return Redacted }
+
+// Format implements fmt.Formatter and prints Redacted for all verbs.
+func (s Secret) Format(f fmt.State, verb rune) { f.Write([]byte(Redacted)) }
+
+// MarshalText implements encoding.TextMarshaler and returns the reference of
+// Secret.
+func (s Secret) MarshalText() ([]byte, error) { return []byte(s.ref), nil }
+
+// UnmarshalText implements encoding.TextUnmarshaler by setting the reference
+// in text if a SecretResolver is registered for its scheme. The reference is
+// not resolved.
+func (s *Secret) UnmarshalText(text []byte) error {
+	ref := string(text)
+	if ref == "" {
+		*s = Secret{}
+		return nil
+	}
+	i := strings.IndexByte(ref, ':')
+	if i < 0 {
+		return ErrNoSecretResolver.WrapArgs(ref)
+	}
+	if _, ok := secretResolver(ref[:i]); !ok {
+		return ErrNoSecretResolver.WrapArgs(ref[:i])
+	}
+	*s = Secret{ref: ref}
+	return nil
+}
+
+// GobEncode implements gob.GobEncoder and returns the reference of Secret.
+func (s Secret) GobEncode() ([]byte, error) { return s.MarshalText() }
+
+// GobDecode implements gob.GobDecoder like UnmarshalText.
+func (s *Secret) GobDecode(data []byte) error { return s.UnmarshalText(data) }
+
+// resolve resolves the reference of Secret if not already resolved.
+func (s *Secret) resolve() error {
+	if s.resolved || s.ref == "" {
+		return nil
+	}
+	i := strings.IndexByte(s.ref, ':')
+	if i < 0 {
+		return ErrNoSecretResolver.WrapArgs(s.ref)
+	}
+	resolver, ok := secretResolver(s.ref[:i])
+	if !ok {
+		return ErrNoSecretResolver.WrapArgs(s.ref[:i])
+	}
+	value, err := resolver.Resolve(s.ref[i+1:])
+	if err != nil {
+		return ErrSecret.WrapCauseArgs(err, s.ref)
+	}
+	s.value, s.resolved = value, true
+	return nil
+}
+
+// secretResolver returns the SecretResolver registered for scheme and true
+// or false if not found.
+func secretResolver(scheme string) (SecretResolver, bool) {
+	secretmu.Lock()
+	defer secretmu.Unlock()
+	resolver, ok := secretResolvers[scheme]
+	return resolver, ok
+}
+
+// unmarshalText unmarshals text into tu and resolves tu if it is a Secret.
+func unmarshalText(tu encoding.TextUnmarshaler, text string) error {
+	if err := tu.UnmarshalText([]byte(text)); err != nil {
+		return err
+	}
+	if s, ok := tu.(*Secret); ok {
+		return s.resolve()
+	}
+	return nil
+}
+
+// secretType is the reflect.Type of Secret.
+var secretType = reflect.TypeOf(Secret{})
+
+// resolveSecrets resolves unresolved Secrets at any depth in v. Secrets in
+// map elements are resolved in copies that are stored back to maps.
+func resolveSecrets(v reflect.Value) error {
+	return resolveSecretsSeen(v, make(map[uintptr]bool))
+}
+
+// resolveSecretsSeen is the implementation of resolveSecrets. Seen holds
+// visited pointers.
+func resolveSecretsSeen(v reflect.Value, seen map[uintptr]bool) error {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() || seen[v.Pointer()] {
+			return nil
+		}
+		seen[v.Pointer()] = true
+		return resolveSecretsSeen(v.Elem(), seen)
+	case reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return resolveSecretsSeen(v.Elem(), seen)
+	case reflect.Struct:
+		if v.Type() == secretType {
+			if !v.CanAddr() {
+				return nil
+			}
+			return v.Addr().Interface().(*Secret).resolve()
+		}
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath != "" {
+				continue
+			}
+			if err := resolveSecretsSeen(v.Field(i), seen); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := resolveSecretsSeen(v.Index(i), seen); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if !hasSecret(v.Type().Elem(), nil) {
+			return nil
+		}
+		for _, key := range v.MapKeys() {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(v.MapIndex(key))
+			if err := resolveSecretsSeen(elem, seen); err != nil {
+				return err
+			}
+			v.SetMapIndex(key, elem)
+		}
+	}
+	return nil
+}
+
+// hasSecret returns true if values of type t can contain a Secret that is not
+// behind a pointer. Visiting holds struct types being checked.
+func hasSecret(t reflect.Type, visiting []reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Struct:
+		if t == secretType {
+			return true
+		}
+		for _, vt := range visiting {
+			if vt == t {
+				return false
+			}
+		}
+		for i := 0; i < t.NumField(); i++ {
+			if hasSecret(t.Field(i).Type, append(visiting, t)) {
+				return true
+			}
+		}
+	case reflect.Array:
+		return hasSecret(t.Elem(), visiting)
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSecret(t *testing.T) {
+	type Config struct {
+		User     string
+		Password Secret
+		Token    *Secret
+		Key      Secret
+		Empty    Secret
+	}
+	token, err := ioutil.TempFile("", "configtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(token.Name())
+	if _, err := token.WriteString("t0k3n\n"); err != nil {
+		t.Fatal(err)
+	}
+	token.Close()
+	defer setenv(map[string]string{"CONFIGTEST_PASS": "p4ss"})()
+	RegisterSecretResolver("test", SecretResolverFunc(func(ref string) (string, error) {
+		return strings.ToUpper(ref), nil
+	}))
+	defer func() {
+		secretmu.Lock()
+		delete(secretResolvers, "test")
+		secretmu.Unlock()
+	}()
+	for _, ext := range []string{"json", "yaml", "toml"} {
+		filename := "testsecret." + ext
+		data := map[string]string{
+			"json": `{"User": "admin", "Password": "env:CONFIGTEST_PASS", "Token": "file:` + token.Name() + `", "Key": "test:k3y"}`,
+			"yaml": "User: admin\nPassword: env:CONFIGTEST_PASS\nToken: file:" + token.Name() + "\nKey: test:k3y\n",
+			"toml": "User = \"admin\"\nPassword = \"env:CONFIGTEST_PASS\"\nToken = \"file:" + token.Name() + "\"\nKey = \"test:k3y\"\n",
+		}[ext]
+		if err := ioutil.WriteFile(filename, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(filename)
+		in := &Config{}
+		if err := ReadConfigFile(filename, in); err != nil {
+			t.Fatal(err)
+		}
+		if in.Password.Value() != "p4ss" || in.Token.Value() != "t0k3n" || in.Key.Value() != "K3Y" || in.Empty.Ref() != "" {
+			t.Fatalf("ReadConfigFile failed (%s): %q, %q, %q", ext, in.Password.Value(), in.Token.Value(), in.Key.Value())
+		}
+		for _, format := range []string{"%v", "%+v", "%#v", "%s", "%x", "%d"} {
+			if s := fmt.Sprintf(format, in); strings.Contains(s, "p4ss") || strings.Contains(s, "t0k3n") || strings.Contains(s, "K3Y") {
+				t.Fatalf("Secret not redacted (%s): %s", format, s)
+			}
+		}
+		if err := WriteConfigFile(filename, in); err != nil {
+			t.Fatal(err)
+		}
+		written, err := ioutil.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(written), "p4ss") || !strings.Contains(string(written), "env:CONFIGTEST_PASS") {
+			t.Fatalf("WriteConfigFile failed (%s): %s", ext, written)
+		}
+	}
+	password, err := NewSecret("env:CONFIGTEST_PASS")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := NewSecret("test:k3y")
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := "testsecret.gob"
+	if err := WriteConfigFile(filename, &Config{User: "admin", Password: password, Token: &key}); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(filename)
+	written, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(written), "p4ss") || strings.Contains(string(written), "K3Y") ||
+		!strings.Contains(string(written), "env:CONFIGTEST_PASS") {
+		t.Fatalf("WriteConfigFile failed (gob): %q", written)
+	}
+	in := &Config{}
+	if err := ReadConfigFile(filename, in); err != nil {
+		t.Fatal(err)
+	}
+	if in.User != "admin" || in.Password.Value() != "p4ss" || in.Token == nil || in.Token.Value() != "K3Y" || in.Key.Ref() != "" {
+		t.Fatalf("ReadConfigFile failed (gob): %#v", in)
+	}
+	if _, err := NewSecret("none:foo"); !errors.Is(err, ErrNoSecretResolver) {
+		t.Fatalf("NewSecret failed: expected ErrNoSecretResolver, got %v", err)
+	}
+	if _, err := NewSecret("env:CONFIGTEST_NONE"); !errors.Is(err, ErrSecret) {
+		t.Fatalf("NewSecret failed: expected ErrSecret, got %v", err)
+	}
+}
+
+func TestSecretNoBackup(t *testing.T) {
+	type Config struct {
+		User     string
+		Password Secret
+	}
+	dir, err := ioutil.TempDir("", "configtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer setenv(map[string]string{"CONFIGTEST_PASS": "p4ss"})()
+	filename := filepath.Join(dir, "config.json")
+	if err := ioutil.WriteFile(filename, []byte(`{"User": "admin", "Password": "env:CONFIGTEST_PASS"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteConfigFileBackup(filename, &Config{User: "other"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filename, []byte(`{"User": "admin", "Password": "env:CONFIGTEST_NONE"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	in := &Config{}
+	if err := ReadConfigFile(filename, in); !errors.Is(err, ErrSecret) || errors.Is(err, ErrBackupRead) {
+		t.Fatalf("ReadConfigFile failed: expected ErrSecret, got %v", err)
+	}
+	if in.Password.Value() == "p4ss" {
+		t.Fatal("ReadConfigFile failed: backup read")
+	}
+}
@@ -0,0 +1,202 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// Config file watching and reloading.
+
+package config
+
+import (
+	"crypto/sha256"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultWatchInterval is the interval at which Dir.Watch polls config files
+// for changes.
+const DefaultWatchInterval = 1 * time.Second
+
+// Watcher watches config files of a Dir for changes and reloads them.
+// It is returned by Dir.Watch.
+type Watcher struct {
+	mu     sync.Mutex                  // mu guards out.
+	out    reflect.Value               // out is the config being reloaded.
+	init   reflect.Value               // init is a copy of out before the initial load.
+	files  func() []string             // files returns paths of watched files.
+	stamps map[string]fileStamp        // stamps are last seen stamps of watched files.
+	load   func(out interface{}) error // load loads and sanitizes a config.
+	err    error                       // err is the last reload error.
+	errmu  sync.Mutex                  // errmu guards err.
+	stop   chan struct{}               // stop signals the watch loop to stop.
+	done   chan struct{}               // done is closed when the watch loop exits.
+	once   sync.Once                   // once guards stop.
+}
+
+// fileStamp identifies a version of a file.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+	hash    [sha256.Size]byte
+	exists  bool
+}
+
+// statFile returns the fileStamp of a file specified by filename. Contents
+// are hashed so that rewrites that keep modification time and size of the
+// file are detected.
+func statFile(filename string) fileStamp {
+	fi, err := os.Stat(filename)
+	if err != nil {
+		return fileStamp{}
+	}
+	stamp := fileStamp{modTime: fi.ModTime(), size: fi.Size(), exists: true}
+	if data, err := ioutil.ReadFile(filename); err == nil {
+		stamp.hash = sha256.Sum256(data)
+	}
+	return stamp
+}
+
+// Watch loads the config specified by name into out using LoadDropIns then
+// watches the config file in all locations for changes using
+// DefaultWatchInterval. See WatchInterval for details.
+func (d *Dir) Watch(name string, out interface{}, onChange func(old, new interface{})) (*Watcher, error) {
+	return d.WatchInterval(name, DefaultWatchInterval, out, onChange)
+}
+
+// WatchInterval loads the config specified by name into out using
+// LoadDropIns then polls the config file in all locations of Dir, files it
+// includes and drop-in fragments of the config at specified interval for
+// changes. Files that are created, modified or removed trigger a reload.
+// Files are compared by modification time, size and a hash of contents.
+//
+// Config is reloaded into a copy of the value of out before the initial load,
+// so that values set by the caller before watching are retained, using
+// LoadDropIns and sanitized using Sanitize. If the reloaded value differs from out as
+// reported by CompareValues it is copied to out and onChange, if not nil, is
+// called with a pointer to a copy of the previous value and a pointer to a
+// copy of the new value. onChange is called from the watch goroutine and
+// must not call Watcher.Lock.
+//
+// Out is modified from the watch goroutine while Watcher lock is held. Use
+// Watcher.Lock and Watcher.Unlock when accessing out while it is watched.
+//
+// Reload errors, except warnings returned by Sanitize and ErrBackupRead,
+// retain out unmodified and are retrievable using Watcher.Err.
+//
+// If out is not a pointer to a struct an ErrInvalidParam is returned.
+// If the initial load fails the error is returned and nothing is watched.
+func (d *Dir) WatchInterval(name string, interval time.Duration, out interface{}, onChange func(old, new interface{})) (*Watcher, error) {
+	v := reflect.ValueOf(out)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return nil, ErrInvalidParam
+	}
+	w := &Watcher{
+		out:  v.Elem(),
+		init: copyValue(v.Elem()),
+		files: func() []string {
+			paths := d.configPaths(name)
+			fragments, _ := d.dropIns(strings.TrimSuffix(name, filepath.Ext(name)) + DropInSuffix)
+			seen := make(map[string]bool)
+			for _, path := range append(paths, fragments...) {
+				paths = append(paths, includedFiles(path, seen)...)
+			}
+			return append(paths, fragments...)
+		},
+		load: func(out interface{}) error {
+			if _, err := d.LoadDropIns(name, out); err != nil && !errors.Is(err, ErrBackupRead) {
+				return err
+			}
+			if err := Sanitize(out); err != nil && !errors.Is(err, ErrWarning) {
+				return err
+			}
+			return nil
+		},
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	w.stamps = w.stat()
+	if err := w.load(out); err != nil {
+		return nil, err
+	}
+	go w.watch(interval, onChange)
+	return w, nil
+}
+
+// Lock locks the config being watched for access.
+func (w *Watcher) Lock() { w.mu.Lock() }
+
+// Unlock unlocks the config being watched.
+func (w *Watcher) Unlock() { w.mu.Unlock() }
+
+// Err returns the error of the last reload or nil if it succeeded.
+func (w *Watcher) Err() error {
+	w.errmu.Lock()
+	defer w.errmu.Unlock()
+	return w.err
+}
+
+// Close stops watching and waits for any reload in progress to complete.
+func (w *Watcher) Close() error {
+	w.once.Do(func() { close(w.stop) })
+	<-w.done
+	return nil
+}
+
+// stat returns stamps of watched files by path.
+func (w *Watcher) stat() map[string]fileStamp {
+	stamps := make(map[string]fileStamp)
+	for _, path := range w.files() {
+		stamps[path] = statFile(path)
+	}
+	return stamps
+}
+
+// watch polls watched files at interval until stopped.
+func (w *Watcher) watch(interval time.Duration, onChange func(old, new interface{})) {
+	defer close(w.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+		}
+		stamps := w.stat()
+		if reflect.DeepEqual(stamps, w.stamps) {
+			continue
+		}
+		w.stamps = stamps
+		w.reload(onChange)
+	}
+}
+
+// reload reloads the watched config and calls onChange if it changed.
+func (w *Watcher) reload(onChange func(old, new interface{})) {
+	nv := reflect.New(w.out.Type())
+	nv.Elem().Set(copyValue(w.init))
+	err := w.load(nv.Interface())
+	w.errmu.Lock()
+	w.err = err
+	w.errmu.Unlock()
+	if err != nil {
+		return
+	}
+	w.mu.Lock()
+	if CompareValues(w.out, nv.Elem()) == 0 {
+		w.mu.Unlock()
+		return
+	}
+	old := reflect.New(w.out.Type())
+	old.Elem().Set(copyValue(w.out))
+	w.out.Set(copyValue(nv.Elem()))
+	w.mu.Unlock()
+	if onChange != nil {
+		onChange(old.Interface(), nv.Interface())
+	}
+}
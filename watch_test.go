@@ -0,0 +1,138 @@
+// Copyright 2020 Vedran Vuk. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDirWatch(t *testing.T) {
+	type Config struct {
+		Name   string `config:"default=foo"`
+		Labels map[string]string
+	}
+	dir, err := NewDir("configtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dir.RemoveUser()
+	filename := filepath.Join(dir.User(), "watch.json")
+	write := func(data string, mtime time.Time) {
+		if err := ioutil.WriteFile(filename, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(filename, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Now()
+	write(`{"Labels": {"x": "1"}}`, now)
+	changes := make(chan [2]*Config, 1)
+	out := &Config{}
+	w, err := dir.WatchInterval("watch.json", 10*time.Millisecond, out, func(old, new interface{}) {
+		changes <- [2]*Config{old.(*Config), new.(*Config)}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if out.Name != "foo" || out.Labels["x"] != "1" {
+		t.Fatalf("Watch failed: %#v", out)
+	}
+	write(`{"Labels": {"x": "1"} }`, now.Add(time.Second))
+	select {
+	case c := <-changes:
+		t.Fatalf("Watch failed: unexpected change %#v", c[1])
+	case <-time.After(100 * time.Millisecond):
+	}
+	write(`{"Labels": {"x": "2"}}`, now.Add(2*time.Second))
+	select {
+	case c := <-changes:
+		if c[0].Labels["x"] != "1" || c[1].Labels["x"] != "2" || c[1].Name != "foo" {
+			t.Fatalf("Watch failed: %#v -> %#v", c[0], c[1])
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Watch failed: change not detected")
+	}
+	write(`{"Labels": {"x": "3"}}`, now.Add(2*time.Second))
+	select {
+	case c := <-changes:
+		if c[0].Labels["x"] != "2" || c[1].Labels["x"] != "3" {
+			t.Fatalf("Watch failed: %#v -> %#v", c[0], c[1])
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Watch failed: change with same mtime and size not detected")
+	}
+	w.Lock()
+	if out.Labels["x"] != "3" {
+		t.Fatalf("Watch failed: %#v", out)
+	}
+	w.Unlock()
+	if err := w.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDirWatchIncludes(t *testing.T) {
+	type Config struct {
+		Name  string
+		Port  int
+		Level string
+	}
+	dir, err := NewDir("configtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dir.RemoveUser()
+	if err := os.MkdirAll(filepath.Join(dir.User(), "watchinc.d"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	write := func(name, data string, mtime time.Time) {
+		filename := filepath.Join(dir.User(), name)
+		if err := ioutil.WriteFile(filename, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(filename, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("watchinc.json", `{"$include": "base.json"}`, now)
+	write("base.json", `{"Port": 80}`, now)
+	changes := make(chan *Config, 1)
+	out := &Config{Name: "initial"}
+	w, err := dir.WatchInterval("watchinc.json", 10*time.Millisecond, out, func(old, new interface{}) {
+		changes <- new.(*Config)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if out.Name != "initial" || out.Port != 80 {
+		t.Fatalf("Watch failed: %#v", out)
+	}
+	write("base.json", `{"Port": 8080}`, now.Add(time.Second))
+	select {
+	case c := <-changes:
+		if c.Name != "initial" || c.Port != 8080 {
+			t.Fatalf("Watch failed: %#v", c)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Watch failed: include change not detected")
+	}
+	write(filepath.Join("watchinc.d", "10-level.json"), `{"Level": "debug"}`, now)
+	select {
+	case c := <-changes:
+		if c.Name != "initial" || c.Port != 8080 || c.Level != "debug" {
+			t.Fatalf("Watch failed: %#v", c)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Watch failed: drop-in change not detected")
+	}
+}